@@ -0,0 +1,64 @@
+package main
+
+import (
+	"html/template"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Redirect page from old link of article to its current link
+type AliasPage struct {
+	// Output path relative to public folder
+	Path string
+	// Link of article
+	Target string
+	// Canonical url of article
+	Canonical string
+	Lang      string
+}
+
+const ALIAS_TEMPLATE = `<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Target}}</title>
+<link rel="canonical" href="{{.Canonical}}">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url={{.Target}}">
+</head>
+<body><a href="{{.Target}}">{{.Target}}</a></body>
+</html>
+`
+
+var aliasTpl = template.Must(template.New("alias").Parse(ALIAS_TEMPLATE))
+
+// Get redirect pages of article aliases, links with query can not be generated
+func ArticleAliases(article *Article) []AliasPage {
+	pages := make([]AliasPage, 0, len(article.Aliases))
+	// Aliases equal to the current link or to each other are skipped
+	seen := map[string]bool{filepath.Clean(LinkPath(article.Link)): true}
+	for _, alias := range article.Aliases {
+		if alias == "" || strings.ContainsAny(alias, "?#") {
+			Warn("Unsupported alias " + alias + " of " + article.Link)
+			continue
+		}
+		// Clean as absolute path so that alias stays in public folder
+		link := strings.TrimPrefix(path.Clean("/"+alias), "/")
+		if strings.HasSuffix(alias, "/") || path.Ext(link) == "" {
+			link += "/"
+		}
+		outPath := filepath.Clean(LinkPath(link))
+		if seen[outPath] {
+			continue
+		}
+		seen[outPath] = true
+		pages = append(pages, AliasPage{
+			Path:      outPath,
+			Target:    globalConfig.Site.Root + "/" + article.Link,
+			Canonical: article.PageSeo.Url,
+			Lang:      globalConfig.Site.Lang,
+		})
+	}
+	return pages
+}
@@ -14,24 +14,30 @@ import (
 )
 
 // Parse config
-var articleTpl, pageTpl, archiveTpl, tagTpl template.Template
+var listTpl, archiveTpl, tagTpl template.Template
 var themePath, publicPath, sourcePath string
+var listTplPath, archiveTplPath, tagTplPath, notFoundTplPath string
 
 // For concurrency
 var wg sync.WaitGroup
 
 // Data struct
 type ArticleInfo struct {
-	DetailDate int64
-	Date       string
-	Title      string
-	Link       string
-	Top        bool
+	DetailDate  int64
+	Date        string
+	Title       string
+	Link        string
+	Top         bool
+	WordCount   int
+	ReadingTime int
+	Summary     string
 }
 
 type Archive struct {
 	Year     string
 	Articles Collections
+	// Words of articles in the year
+	WordCount int
 }
 
 type Tag struct {
@@ -70,34 +76,135 @@ func (v Collections) Less(i, j int) bool {
 	return false
 }
 
-func Build() {
+// Build site, errors are returned instead of exiting in preview mode
+func Build() error {
+	buildErrors.Reset()
+	outputPath := filepath.Join(rootPath, globalConfig.Build.Output)
+	publicPath = outputPath
+	if globalConfig.Develop {
+		// Build to staging folder so that the last good output is kept on failure
+		publicPath = StagingPath(outputPath)
+		os.RemoveAll(publicPath)
+	}
+	err := CatchBuildError(build)
+	// Wait for started goroutines even if build is stopped
+	wg.Wait()
+	if err == nil {
+		err = buildErrors.First()
+	}
+	if globalConfig.Develop {
+		stagingPath := publicPath
+		publicPath = outputPath
+		if err == nil {
+			err = PublishStaging(stagingPath, outputPath)
+		}
+		os.RemoveAll(stagingPath)
+	}
+	if err == nil {
+		err = WriteManifest(outputPath)
+	}
+	return err
+}
+
+// Get file listing outputs of last build next to output folder
+func ManifestPath(outputPath string) string {
+	return filepath.Join(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".manifest")
+}
+
+// Record planned and compressed outputs so that the next build can remove stale files
+func WriteManifest(outputPath string) error {
+	paths := append(buildPlan.Paths(), buildPlan.Compressed...)
+	for i, outPath := range paths {
+		paths[i] = filepath.ToSlash(outPath)
+	}
+	return os.WriteFile(ManifestPath(outputPath), []byte(strings.Join(paths, "\n")+"\n"), 0644)
+}
+
+// Get staging folder next to output folder
+func StagingPath(outputPath string) string {
+	return filepath.Join(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".staging")
+}
+
+// Remove generated files of last build in output folder
+func CleanOutput(outputPath string) {
+	// Outputs of last build, including pretty urls and permalinks
+	if data, err := os.ReadFile(ManifestPath(outputPath)); err == nil {
+		dirs := make([]string, 0)
+		for _, outPath := range strings.Split(string(data), "\n") {
+			outPath = filepath.Clean(filepath.FromSlash(outPath))
+			if outPath == "." || outPath == ".." || strings.HasPrefix(outPath, ".."+string(filepath.Separator)) || filepath.IsAbs(outPath) {
+				continue
+			}
+			fullPath := filepath.Join(outputPath, outPath)
+			os.Remove(fullPath)
+			dirs = append(dirs, filepath.Dir(fullPath))
+		}
+		// Remove emptied folders and their emptied parents
+		for _, dir := range dirs {
+			for dir != filepath.Clean(outputPath) && os.Remove(dir) == nil {
+				dir = filepath.Dir(dir)
+			}
+		}
+	}
+	cleanPatterns := []string{"post", "tag", "images", "js", "css", "*.html", "favicon.ico", "robots.txt"}
+	for _, pattern := range cleanPatterns {
+		files, _ := filepath.Glob(filepath.Join(outputPath, pattern))
+		for _, path := range files {
+			os.RemoveAll(path)
+		}
+	}
+}
+
+// Move files built in staging folder to output folder
+func PublishStaging(stagingPath string, outputPath string) error {
+	if err := os.MkdirAll(outputPath, 0777); err != nil {
+		return err
+	}
+	CleanOutput(outputPath)
+	entries, err := os.ReadDir(stagingPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		target := filepath.Join(outputPath, entry.Name())
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(stagingPath, entry.Name()), target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func build() {
 	startTime := time.Now()
 	var articles = make(Collections, 0)
 	var visibleArticles = make(Collections, 0)
 	var pages = make(Collections, 0)
 	var tagMap = make(map[string]Collections)
 	var archiveMap = make(map[string]Collections)
+	var aliases = make([]AliasPage, 0)
+	var aliasSources = make([]string, 0)
+	// Plan is created first so that watcher can snapshot outputs of failed build
+	buildPlan = NewBuildPlan()
+	usedCards = make(map[string]bool)
 	// Parse config
-	themePath = filepath.Join(rootPath, globalConfig.Site.Theme)
-	publicPath = filepath.Join(rootPath, globalConfig.Build.Output)
+	themePath = themeConfig.Chain[0]
 	sourcePath = filepath.Join(rootPath, "source")
-	// Append all partial html
-	var partialTpl string
-	files, _ := filepath.Glob(filepath.Join(themePath, "*.html"))
-	for _, path := range files {
-		fileExt := strings.ToLower(filepath.Ext(path))
-		baseName := strings.ToLower(filepath.Base(path))
-		if fileExt == ".html" && strings.HasPrefix(baseName, "_") {
-			html, err := os.ReadFile(path)
-			if err != nil {
-				Fatal(err.Error())
-			}
-			tplName := strings.TrimPrefix(baseName, "_")
-			tplName = strings.TrimSuffix(tplName, ".html")
-			htmlStr := "{{define \"" + tplName + "\"}}" + string(html) + "{{end}}"
-			partialTpl += htmlStr
-		}
+	configPath := filepath.Join(rootPath, "config.yml")
+	templateDirs = TemplateDirs(rootPath, themeConfig)
+	listTplPath = LookupListTemplate()
+	archiveTplPath = MustLookupTemplate("archive.html")
+	tagTplPath = MustLookupTemplate("tag.html")
+	notFoundTplPath = LookupTemplate("404.html")
+	gitHistory = nil
+	if globalConfig.Build.GitInfo {
+		gitHistory = LoadGitHistory(rootPath)
 	}
+	InitMinifier(globalConfig.Build.Minify)
+	// Append all partial html
+	partials := LookupPartials()
 	// Compile template
 	funcCxt := FuncContext{
 		rootPath:   rootPath,
@@ -106,18 +213,14 @@ func Build() {
 		global:     globalConfig,
 		currentCwd: themePath,
 	}
-	articleTpl = CompileTpl(filepath.Join(themePath, "article.html"), partialTpl, "article", funcCxt)
-	pageTpl = CompileTpl(filepath.Join(themePath, "page.html"), partialTpl, "page", funcCxt)
-	archiveTpl = CompileTpl(filepath.Join(themePath, "archive.html"), partialTpl, "archive", funcCxt)
-	tagTpl = CompileTpl(filepath.Join(themePath, "tag.html"), partialTpl, "tag", funcCxt)
+	articleTpls = NewArticleTpls(partials, funcCxt)
+	shortcodeTpls = NewThemeTpls(SHORTCODES_DIR, partials, funcCxt)
+	renderHookTpls = NewThemeTpls("", partials, funcCxt)
+	listTpl = CompileTpl(listTplPath, partials, "list", funcCxt)
+	archiveTpl = CompileTpl(archiveTplPath, partials, "archive", funcCxt)
+	tagTpl = CompileTpl(tagTplPath, partials, "tag", funcCxt)
 	// Clean public folder
-	cleanPatterns := []string{"post", "tag", "images", "js", "css", "*.html", "favicon.ico", "robots.txt"}
-	for _, pattern := range cleanPatterns {
-		files, _ := filepath.Glob(filepath.Join(publicPath, pattern))
-		for _, path := range files {
-			os.RemoveAll(path)
-		}
-	}
+	CleanOutput(publicPath)
 	// Find all .md to generate article
 	symwalk.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
 		fileExt := strings.ToLower(filepath.Ext(path))
@@ -127,12 +230,29 @@ func Build() {
 			if article == nil || article.Draft {
 				return nil
 			}
+			// Skip article if output path is already used
+			outPath := LinkPath(article.Link)
+			if !buildPlan.Add(outPath, path) {
+				return nil
+			}
+			// Compile template before rendering to report errors early
+			article.tplPath = ArticleTplPath(article, path)
+			articleTpls.Get(article.tplPath)
 			Log("Building " + article.Link)
 			// Generate file path
-			directory := filepath.Dir(article.Link)
+			directory := filepath.Dir(outPath)
 			err := os.MkdirAll(filepath.Join(publicPath, directory), 0777)
 			if err != nil {
-				Fatal(err.Error())
+				BuildFatal(err.Error())
+			}
+			if article.cardPath != "" && buildPlan.Add(article.cardPath, path) {
+				wg.Add(1)
+				go RenderCard(*article)
+			}
+			// Aliases are planned after other outputs so that they never take over a page
+			for _, alias := range ArticleAliases(article) {
+				aliases = append(aliases, alias)
+				aliasSources = append(aliasSources, path)
 			}
 			// Append to collections
 			if article.Type == "page" {
@@ -157,37 +277,86 @@ func Build() {
 				archiveMap[dateYear] = make(Collections, 0)
 			}
 			articleInfo := ArticleInfo{
-				DetailDate: article.Date,
-				Date:       article.Time.Format("2006-01-02"),
-				Title:      article.Title,
-				Link:       article.Link,
-				Top:        article.Top,
+				DetailDate:  article.Date,
+				Date:        article.Time.Format("2006-01-02"),
+				Title:       article.Title,
+				Link:        article.Link,
+				Top:         article.Top,
+				WordCount:   article.WordCount,
+				ReadingTime: article.ReadingTime,
+				Summary:     article.Summary,
 			}
 			archiveMap[dateYear] = append(archiveMap[dateYear], articleInfo)
 		}
 		return nil
 	})
 	if len(visibleArticles) == 0 {
-		Fatal("Must be have at least one article")
+		BuildFatal("Must be have at least one article")
 	}
 	// Sort by date
 	sort.Sort(articles)
 	sort.Sort(visibleArticles)
+	// Plan other outputs before rendering
+	tagNames := make([]string, 0, len(tagMap))
+	for tagName := range tagMap {
+		tagNames = append(tagNames, tagName)
+	}
+	sort.Strings(tagNames)
+	for i := 0; i < ListPageCount(len(visibleArticles)); i++ {
+		buildPlan.Add(ListPagePath("", i), listTplPath)
+	}
+	for _, tagName := range tagNames {
+		for i := 0; i < ListPageCount(len(tagMap[tagName])); i++ {
+			buildPlan.Add(ListPagePath(filepath.Join("tag", tagName), i), listTplPath)
+		}
+	}
+	buildPlan.Add("archive.html", archiveTplPath)
+	buildPlan.Add("tag.html", tagTplPath)
+	// Not found page of site overrides the one of theme
+	if notFoundTplPath != "" && !Exists(filepath.Join(sourcePath, "404.html")) {
+		buildPlan.Add("404.html", notFoundTplPath)
+	}
+	if globalConfig.Site.Url != "" {
+		buildPlan.Add("atom.xml", configPath)
+		buildPlan.Add("sitemap.xml", configPath)
+	}
+	buildPlan.Add("index.json", configPath)
+	htmlPages := buildPlan.AddSourcePages()
+	copyItems := buildPlan.AddCopy(globalConfig.Build.Copy)
+	copyItems = append(copyItems, buildPlan.AddThemeCopy(themeConfig.Copy)...)
+	// Generate redirect pages of aliases
+	for i, alias := range aliases {
+		if !buildPlan.Add(alias.Path, aliasSources[i]) {
+			continue
+		}
+		aliasPath := filepath.Join(publicPath, alias.Path)
+		if err := os.MkdirAll(filepath.Dir(aliasPath), 0777); err != nil {
+			BuildFatal(err.Error())
+		}
+		wg.Add(1)
+		go RenderPage(*aliasTpl, alias, aliasPath)
+	}
 	// Generate RSS page
-	wg.Add(1)
-	go GenerateRSS(visibleArticles)
+	if buildPlan.Owns("atom.xml", configPath) {
+		wg.Add(1)
+		go GenerateRSS(visibleArticles)
+	}
 	// Generate sitemap page
-	wg.Add(1)
-	go GenerateSitemap(visibleArticles)
+	if buildPlan.Owns("sitemap.xml", configPath) {
+		wg.Add(1)
+		go GenerateSitemap(visibleArticles)
+	}
 	// Generate article list JSON
-	wg.Add(1)
-	go GenerateJSON(visibleArticles)
+	if buildPlan.Owns("index.json", configPath) {
+		wg.Add(1)
+		go GenerateJSON(visibleArticles)
+	}
 	// Render articles
 	wg.Add(1)
-	go RenderArticles(articleTpl, articles)
+	go RenderArticles(articles)
 	// Render pages
 	wg.Add(1)
-	go RenderArticles(articleTpl, pages)
+	go RenderArticles(pages)
 	// Generate article list pages
 	wg.Add(1)
 	go RenderArticleList("", visibleArticles, "")
@@ -202,20 +371,34 @@ func Build() {
 	for year, articleInfos := range archiveMap {
 		// Sort by date
 		sort.Sort(articleInfos)
+		wordCount := 0
+		for _, articleInfo := range articleInfos {
+			wordCount += articleInfo.(ArticleInfo).WordCount
+		}
 		archives = append(archives, Archive{
-			Year:     year,
-			Articles: articleInfos,
+			Year:      year,
+			Articles:  articleInfos,
+			WordCount: wordCount,
 		})
 	}
 	// Sort by year
 	sort.Sort(archives)
-	wg.Add(1)
-	go RenderPage(archiveTpl, map[string]interface{}{
-		"Total":   len(visibleArticles),
-		"Archive": archives,
-		"Site":    globalConfig.Site,
-		"I18n":    globalConfig.I18n,
-	}, filepath.Join(publicPath, "archive.html"))
+	if buildPlan.Owns("archive.html", archiveTplPath) {
+		totalWords := 0
+		for _, archive := range archives {
+			totalWords += archive.(Archive).WordCount
+		}
+		wg.Add(1)
+		go RenderPage(archiveTpl, map[string]interface{}{
+			"Total":       len(visibleArticles),
+			"WordCount":   totalWords,
+			"ReadingTime": ReadingTime(totalWords),
+			"Archive":     archives,
+			"Site":        globalConfig.Site,
+			"I18n":        globalConfig.I18n,
+			"PageSeo":     SiteSeo("archive.html", globalConfig.I18n["archive"]),
+		}, filepath.Join(publicPath, "archive.html"))
+	}
 	// Generate tag page
 	tags := make(Collections, 0)
 	for tagName, tagArticles := range tagMap {
@@ -223,11 +406,14 @@ func Build() {
 		for _, article := range tagArticles {
 			articleValue := article.(Article)
 			articleInfos = append(articleInfos, ArticleInfo{
-				DetailDate: articleValue.Date,
-				Date:       articleValue.Time.Format("2006-01-02"),
-				Title:      articleValue.Title,
-				Link:       articleValue.Link,
-				Top:        articleValue.Top,
+				DetailDate:  articleValue.Date,
+				Date:        articleValue.Time.Format("2006-01-02"),
+				Title:       articleValue.Title,
+				Link:        articleValue.Link,
+				Top:         articleValue.Top,
+				WordCount:   articleValue.WordCount,
+				ReadingTime: articleValue.ReadingTime,
+				Summary:     articleValue.Summary,
 			})
 		}
 		// Sort by date
@@ -240,15 +426,28 @@ func Build() {
 	}
 	// Sort by count
 	sort.Sort(Collections(tags))
-	wg.Add(1)
-	go RenderPage(tagTpl, map[string]interface{}{
-		"Total": len(visibleArticles),
-		"Tag":   tags,
-		"Site":  globalConfig.Site,
-		"I18n":  globalConfig.I18n,
-	}, filepath.Join(publicPath, "tag.html"))
+	if buildPlan.Owns("tag.html", tagTplPath) {
+		wg.Add(1)
+		go RenderPage(tagTpl, map[string]interface{}{
+			"Total":   len(visibleArticles),
+			"Tag":     tags,
+			"Site":    globalConfig.Site,
+			"I18n":    globalConfig.I18n,
+			"PageSeo": SiteSeo("tag.html", globalConfig.I18n["tag"]),
+		}, filepath.Join(publicPath, "tag.html"))
+	}
+	// Generate not found page
+	if buildPlan.Owns("404.html", notFoundTplPath) {
+		notFoundTpl := CompileTpl(notFoundTplPath, partials, "404", funcCxt)
+		wg.Add(1)
+		go RenderPage(notFoundTpl, map[string]interface{}{
+			"Site":    globalConfig.Site,
+			"I18n":    globalConfig.I18n,
+			"Develop": globalConfig.Develop,
+			"PageSeo": NotFoundSeo(),
+		}, filepath.Join(publicPath, "404.html"))
+	}
 	// Generate other pages
-	files, _ = filepath.Glob(filepath.Join(sourcePath, "*.html"))
 	funcCxt = FuncContext{
 		rootPath:   rootPath,
 		themePath:  themePath,
@@ -256,46 +455,40 @@ func Build() {
 		global:     globalConfig,
 		currentCwd: sourcePath,
 	}
-	for _, path := range files {
-		fileExt := strings.ToLower(filepath.Ext(path))
+	for _, path := range htmlPages {
 		baseName := filepath.Base(path)
-		if fileExt == ".html" && !strings.HasPrefix(baseName, "_") {
-			htmlTpl := CompileTpl(path, partialTpl, baseName, funcCxt)
-			relPath, _ := filepath.Rel(sourcePath, path)
-			wg.Add(1)
-			go RenderPage(htmlTpl, globalConfig, filepath.Join(publicPath, relPath))
-		}
+		htmlTpl := CompileTpl(path, partials, baseName, funcCxt)
+		relPath, _ := filepath.Rel(sourcePath, path)
+		wg.Add(1)
+		go RenderPage(htmlTpl, SourcePage{globalConfig, SiteSeo(relPath, "")}, filepath.Join(publicPath, relPath))
 	}
 	// Copy static files
-	Copy()
+	Copy(copyItems)
 	wg.Wait()
+	if globalConfig.Build.Card.Enable {
+		PruneCardCache()
+	}
+	buildPlan.Compressed = CompressOutputs(buildPlan.Paths())
 	endTime := time.Now()
 	usedTime := endTime.Sub(startTime)
+	if buildPlan.Collisions > 0 {
+		Error(fmt.Sprintf("Found %d output path collisions", buildPlan.Collisions))
+	}
+	ReportMinifyStats()
 	fmt.Printf("\nFinished to build in public folder (%v)\n", usedTime)
+	if globalConfig.Build.Check {
+		ReportBrokenLinks(CheckLinks())
+	}
 }
 
 // Copy static files
-func Copy() {
-	srcList := globalConfig.Build.Copy
-	for _, source := range srcList {
-		if matches, err := filepath.Glob(filepath.Join(rootPath, source)); err == nil {
-			for _, srcPath := range matches {
-				Log("Copying " + srcPath)
-				file, err := os.Stat(srcPath)
-				if err != nil {
-					Fatal("Not exist: " + srcPath)
-				}
-				fileName := file.Name()
-				desPath := filepath.Join(publicPath, fileName)
-				wg.Add(1)
-				if file.IsDir() {
-					go CopyDir(srcPath, desPath)
-				} else {
-					go CopyFile(srcPath, desPath)
-				}
-			}
-		} else {
-			Fatal(err.Error())
+func Copy(items []CopyItem) {
+	for _, item := range items {
+		Log("Copying " + item.Source)
+		if err := os.MkdirAll(filepath.Dir(item.Target), 0777); err != nil {
+			BuildFatal(err.Error())
 		}
+		wg.Add(1)
+		go CopyFile(item.Source, item.Target)
 	}
 }
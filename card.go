@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+type CardConfig struct {
+	// Generate social cards of articles without cover
+	Enable bool
+	// Size in pixels, default 1200x630
+	Width  int
+	Height int
+	// Background color when theme has no card.png, default #ffffff
+	Background string
+	// Text color, default #333333
+	Color string
+	// Font file relative to blog root, default is card.ttf of theme or a system CJK font
+	Font string
+}
+
+// Theme font of cards, used when build.card.font is not set
+const CARD_FONT = "card.ttf"
+
+// CJK fonts of systems, the first found one is used when neither config nor theme has a font
+var systemCardFonts = []string{
+	"/usr/share/fonts/truetype/wqy/wqy-microhei.ttc",
+	"/usr/share/fonts/wenquanyi/wqy-microhei/wqy-microhei.ttc",
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/noto-cjk/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc",
+	"/System/Library/Fonts/PingFang.ttc",
+	"/System/Library/Fonts/STHeiti Medium.ttc",
+	"C:\\Windows\\Fonts\\msyh.ttc",
+}
+
+// Bump to render cached cards again after layout changes
+const CARD_VERSION = 1
+
+// Theme file drawn as background of cards
+const CARD_BACKGROUND = "card.png"
+
+// Parsed fonts by path, fonts can be shared by faces of goroutines
+var cardFonts = make(map[string]*sfnt.Font)
+var cardFontsLock sync.Mutex
+
+// Cached cards used by current build, others are pruned after build
+var usedCards = make(map[string]bool)
+var usedCardsLock sync.Mutex
+
+// Get output path of article card next to article page
+func CardPath(link string) string {
+	outPath := LinkPath(link)
+	return strings.TrimSuffix(outPath, filepath.Ext(outPath)) + ".png"
+}
+
+// Get card config with defaults
+func cardConfig() CardConfig {
+	config := globalConfig.Build.Card
+	if config.Width <= 0 {
+		config.Width = 1200
+	}
+	if config.Height <= 0 {
+		config.Height = 630
+	}
+	if config.Background == "" {
+		config.Background = "#ffffff"
+	}
+	if config.Color == "" {
+		config.Color = "#333333"
+	}
+	return config
+}
+
+// Get font file of cards from config, theme or system, empty if none is found
+func cardFontPath(config CardConfig) string {
+	if config.Font != "" {
+		return filepath.Join(rootPath, config.Font)
+	}
+	if themeFont := LookupTemplate(CARD_FONT); themeFont != "" {
+		return themeFont
+	}
+	for _, systemFont := range systemCardFonts {
+		if Exists(systemFont) {
+			return systemFont
+		}
+	}
+	return ""
+}
+
+// Parse font of cards once, Go Regular without CJK characters is used if there is no font file
+func loadCardFont(fontPath string) *sfnt.Font {
+	cardFontsLock.Lock()
+	defer cardFontsLock.Unlock()
+	if cardFont, ok := cardFonts[fontPath]; ok {
+		return cardFont
+	}
+	data := goregular.TTF
+	if fontPath == "" {
+		Warn("No CJK font found for cards, please set build.card.font or add " + CARD_FONT + " to theme")
+	} else {
+		fontData, err := os.ReadFile(fontPath)
+		if err != nil {
+			BuildFatal(err.Error())
+		}
+		data = fontData
+	}
+	collection, err := opentype.ParseCollection(data)
+	if err != nil {
+		BuildFatal("Invalid card font " + fontPath + ": " + err.Error())
+	}
+	cardFont, err := collection.Font(0)
+	if err != nil {
+		BuildFatal("Invalid card font " + fontPath + ": " + err.Error())
+	}
+	cardFonts[fontPath] = cardFont
+	return cardFont
+}
+
+// Parse color like #333 or #333333
+func parseHexColor(value string) color.RGBA {
+	digits := strings.TrimPrefix(value, "#")
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	rgb, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) != 6 {
+		BuildFatal("Invalid card color: " + value)
+	}
+	return color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xff}
+}
+
+// Find local image of site, such as avatar and logo
+func cardImagePath(link string) string {
+	if link == "" || strings.Contains(link, "://") || strings.HasPrefix(link, "//") {
+		return ""
+	}
+	link = strings.TrimPrefix(link, globalConfig.Site.Root)
+	link = filepath.FromSlash(strings.TrimPrefix(link, "/"))
+	if path := filepath.Join(sourcePath, link); Exists(path) {
+		return path
+	}
+	return LookupTemplate(link)
+}
+
+// Describe file by size and modification time for cache key
+func fileStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%d", path, info.Size(), info.ModTime().UnixNano())
+}
+
+func loadImage(path string) image.Image {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		Warn("Card image is not decoded: " + path)
+		return nil
+	}
+	return img
+}
+
+// Get card cache folder of blog, cards of each blog are pruned separately
+func cardCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	absRoot, _ := filepath.Abs(rootPath)
+	hash := sha256.Sum256([]byte(absRoot))
+	return filepath.Join(cacheDir, "ink", "cards", hex.EncodeToString(hash[:8]))
+}
+
+// Remove cached cards which are not used by current build
+func PruneCardCache() {
+	entries, err := os.ReadDir(cardCacheDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && !usedCards[entry.Name()] {
+			os.Remove(filepath.Join(cardCacheDir(), entry.Name()))
+		}
+	}
+}
+
+// Render card of article, cached card is used if nothing changed
+func RenderCard(article Article) {
+	defer wg.Done()
+	defer RecoverBuildError()
+	config := cardConfig()
+	avatarPath := cardImagePath(article.Author.Avatar)
+	logoPath := cardImagePath(globalConfig.Site.Logo)
+	backgroundPath := LookupTemplate(CARD_BACKGROUND)
+	fontPath := cardFontPath(config)
+	keyData, _ := json.Marshal([]interface{}{
+		CARD_VERSION, config, article.Title, article.Tags, article.Author.Name, globalConfig.Site.Title,
+		fileStamp(avatarPath), fileStamp(logoPath), fileStamp(backgroundPath), fileStamp(fontPath),
+	})
+	hash := sha256.Sum256(keyData)
+	cacheName := hex.EncodeToString(hash[:16]) + ".png"
+	cachePath := filepath.Join(cardCacheDir(), cacheName)
+	usedCardsLock.Lock()
+	usedCards[cacheName] = true
+	usedCardsLock.Unlock()
+	outPath := filepath.Join(publicPath, article.cardPath)
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		Log("Drawing card " + article.cardPath)
+		var out bytes.Buffer
+		img := drawCard(article, config, loadCardFont(fontPath), loadImage(avatarPath), loadImage(logoPath), loadImage(backgroundPath))
+		if err := png.Encode(&out, img); err != nil {
+			BuildFatal(err.Error())
+		}
+		data = out.Bytes()
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+			os.WriteFile(cachePath, data, 0644)
+		}
+	}
+	if err := os.WriteFile(outPath, data, 0644); err != nil {
+		BuildFatal(err.Error())
+	}
+}
+
+// Draw title, tags, author and site of article
+func drawCard(article Article, config CardConfig, cardFont *sfnt.Font, avatar image.Image, logo image.Image, background image.Image) image.Image {
+	width, height := config.Width, config.Height
+	// Sizes are designed for the height of 630 pixels
+	scale := float64(height) / 630
+	size := func(value float64) int { return int(value * scale) }
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(parseHexColor(config.Background)), image.Point{}, draw.Src)
+	if background != nil {
+		drawCover(dst, dst.Bounds(), background)
+	}
+	textColor := parseHexColor(config.Color)
+	mutedColor := color.NRGBA{textColor.R, textColor.G, textColor.B, 0xaa}
+	padding := size(72)
+	contentWidth := width - padding*2
+	// Tags at top
+	y := padding
+	if len(article.Tags) > 0 {
+		face := newCardFace(cardFont, float64(size(28)))
+		tags := "#" + strings.Join(article.Tags, "  #")
+		lines := wrapText(face, tags, contentWidth)
+		drawText(dst, face, lines[0], padding, y, mutedColor)
+		y += size(28) + size(36)
+		face.Close()
+	} else {
+		y += size(24)
+	}
+	// Title in up to 3 lines, smaller font for longer titles
+	var titleFace font.Face
+	var titleLines []string
+	titleSize := 0
+	for _, fontSize := range []float64{68, 56, 48} {
+		if titleFace != nil {
+			titleFace.Close()
+		}
+		titleSize = size(fontSize)
+		titleFace = newCardFace(cardFont, float64(titleSize))
+		titleLines = wrapText(titleFace, article.Title, contentWidth)
+		if len(titleLines) <= 3 {
+			break
+		}
+	}
+	if len(titleLines) > 3 {
+		// Rest of title overflows the last line and is cut
+		titleLines = append(titleLines[:2], ellipsisText(titleFace, strings.Join(titleLines[2:], " "), contentWidth))
+	}
+	for _, line := range titleLines {
+		drawText(dst, titleFace, line, padding, y, textColor)
+		y += titleSize * 13 / 10
+	}
+	titleFace.Close()
+	// Author at bottom left and site at bottom right
+	iconSize := size(72)
+	iconY := height - padding - iconSize
+	face := newCardFace(cardFont, float64(size(30)))
+	defer face.Close()
+	textY := iconY + (iconSize-size(30))/2
+	x := padding
+	if avatar != nil {
+		rect := image.Rect(x, iconY, x+iconSize, iconY+iconSize)
+		avatarImg := image.NewRGBA(image.Rect(0, 0, iconSize, iconSize))
+		drawCover(avatarImg, avatarImg.Bounds(), avatar)
+		draw.DrawMask(dst, rect, avatarImg, image.Point{}, &circleMask{iconSize}, image.Point{}, draw.Over)
+		x += iconSize + size(20)
+	}
+	if article.Author.Name != "" {
+		drawText(dst, face, article.Author.Name, x, textY, textColor)
+	}
+	right := width - padding
+	if logo != nil {
+		rect := image.Rect(right-iconSize, iconY, right, iconY+iconSize)
+		drawContain(dst, rect, logo)
+		right -= iconSize + size(20)
+	}
+	siteTitle := ellipsisText(face, globalConfig.Site.Title, contentWidth/2)
+	drawText(dst, face, siteTitle, right-font.MeasureString(face, siteTitle).Ceil(), textY, mutedColor)
+	return dst
+}
+
+func newCardFace(cardFont *sfnt.Font, size float64) font.Face {
+	face, err := opentype.NewFace(cardFont, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		BuildFatal(err.Error())
+	}
+	return face
+}
+
+// Draw text with its top at y
+func drawText(dst draw.Image, face font.Face, text string, x int, y int, textColor color.Color) {
+	drawer := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(textColor),
+		Face: face,
+		Dot:  fixed.P(x, y+face.Metrics().Ascent.Ceil()),
+	}
+	drawer.DrawString(text)
+}
+
+// Split text into lines within width, CJK characters can break anywhere
+func wrapText(face font.Face, text string, width int) []string {
+	words := make([]string, 0)
+	var word strings.Builder
+	for _, char := range text {
+		if isCJK(char) || unicode.IsSpace(char) {
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			words = append(words, string(char))
+			continue
+		}
+		word.WriteRune(char)
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	lines := make([]string, 0)
+	line := ""
+	for _, word := range words {
+		if font.MeasureString(face, line+word).Ceil() > width && strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimSpace(line))
+			line = strings.TrimLeftFunc(word, unicode.IsSpace)
+			continue
+		}
+		line += word
+	}
+	if strings.TrimSpace(line) != "" || len(lines) == 0 {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return lines
+}
+
+// Cut text to fit width with ellipsis
+func ellipsisText(face font.Face, text string, width int) string {
+	if font.MeasureString(face, text).Ceil() <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && font.MeasureString(face, string(runes)+"…").Ceil() > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "…"
+}
+
+// Scale image to fill rect, cropping the overflow
+func drawCover(dst draw.Image, rect image.Rectangle, src image.Image) {
+	bounds := src.Bounds()
+	crop := bounds
+	if bounds.Dx()*rect.Dy() > bounds.Dy()*rect.Dx() {
+		cropWidth := bounds.Dy() * rect.Dx() / rect.Dy()
+		crop.Min.X += (bounds.Dx() - cropWidth) / 2
+		crop.Max.X = crop.Min.X + cropWidth
+	} else {
+		cropHeight := bounds.Dx() * rect.Dy() / rect.Dx()
+		crop.Min.Y += (bounds.Dy() - cropHeight) / 2
+		crop.Max.Y = crop.Min.Y + cropHeight
+	}
+	draw.CatmullRom.Scale(dst, rect, src, crop, draw.Over, nil)
+}
+
+// Scale image to fit in rect, keeping its aspect ratio
+func drawContain(dst draw.Image, rect image.Rectangle, src image.Image) {
+	bounds := src.Bounds()
+	target := rect
+	if bounds.Dx()*rect.Dy() > bounds.Dy()*rect.Dx() {
+		targetHeight := rect.Dx() * bounds.Dy() / bounds.Dx()
+		target.Min.Y += (rect.Dy() - targetHeight) / 2
+		target.Max.Y = target.Min.Y + targetHeight
+	} else {
+		targetWidth := rect.Dy() * bounds.Dx() / bounds.Dy()
+		target.Min.X += (rect.Dx() - targetWidth) / 2
+		target.Max.X = target.Min.X + targetWidth
+	}
+	draw.CatmullRom.Scale(dst, target, src, bounds, draw.Over, nil)
+}
+
+// Alpha mask of circle inscribed in square of size
+type circleMask struct {
+	size int
+}
+
+func (mask *circleMask) ColorModel() color.Model { return color.AlphaModel }
+
+func (mask *circleMask) Bounds() image.Rectangle { return image.Rect(0, 0, mask.size, mask.size) }
+
+func (mask *circleMask) At(x, y int) color.Color {
+	radius := float64(mask.size) / 2
+	dx, dy := float64(x)+0.5-radius, float64(y)+0.5-radius
+	if dx*dx+dy*dy <= radius*radius {
+		return color.Alpha{0xff}
+	}
+	return color.Alpha{0}
+}
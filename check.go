@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Broken reference found in rendered page
+type BrokenLink struct {
+	Source string
+	Page   string
+	Link   string
+	Reason string
+}
+
+// Links and anchors of rendered page
+type pageRefs struct {
+	ids   map[string]bool
+	links []pageLink
+}
+
+type pageLink struct {
+	url   string
+	image bool
+}
+
+// Attributes referring to other files
+var linkAttrs = map[string][]string{
+	"a":      {"href"},
+	"link":   {"href"},
+	"img":    {"src", "data-src"},
+	"script": {"src"},
+	"source": {"src"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"iframe": {"src"},
+}
+
+// Parse ids and links of html file
+func parsePageRefs(htmlPath string) (*pageRefs, error) {
+	file, err := os.Open(htmlPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	refs := &pageRefs{ids: make(map[string]bool)}
+	tokenizer := html.NewTokenizer(file)
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		token := tokenizer.Token()
+		attrs := linkAttrs[token.Data]
+		seen := make(map[string]bool)
+		for _, attr := range token.Attr {
+			if attr.Key == "id" || (token.Data == "a" && attr.Key == "name") {
+				refs.ids[attr.Val] = true
+				continue
+			}
+			for _, name := range attrs {
+				// Same link may be repeated in attributes of one element
+				if attr.Key == name && !seen[attr.Val] {
+					seen[attr.Val] = true
+					refs.links = append(refs.links, pageLink{
+						url:   strings.TrimSpace(attr.Val),
+						image: token.Data == "img",
+					})
+				}
+			}
+		}
+	}
+	return refs, nil
+}
+
+// Resolve internal link to output path, return false for external link
+func resolveLink(page string, link string) (target string, fragment string, ok bool) {
+	if link == "" || strings.HasPrefix(link, "//") {
+		return "", "", false
+	}
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "", "", false
+	}
+	fragment = u.Fragment
+	if u.Path == "" {
+		return filepath.ToSlash(page), fragment, true
+	}
+	linkPath := u.Path
+	if strings.HasPrefix(linkPath, "/") {
+		root := globalConfig.Site.Root
+		if root != "" && (linkPath == root || strings.HasPrefix(linkPath, root+"/")) {
+			linkPath = strings.TrimPrefix(linkPath, root)
+		}
+		linkPath = strings.TrimPrefix(linkPath, "/")
+	} else {
+		linkPath = path.Join(path.Dir(filepath.ToSlash(page)), linkPath)
+	}
+	if linkPath == "" || linkPath == "." || strings.HasSuffix(u.Path, "/") {
+		linkPath = path.Join(linkPath, "index.html")
+	}
+	return linkPath, fragment, true
+}
+
+// Check internal links and anchors of all rendered html pages
+func CheckLinks() []BrokenLink {
+	pages := make(map[string]*pageRefs)
+	for _, outPath := range buildPlan.Paths() {
+		if strings.ToLower(filepath.Ext(outPath)) != ".html" {
+			continue
+		}
+		refs, err := parsePageRefs(filepath.Join(publicPath, outPath))
+		if err != nil {
+			Warn(err.Error())
+			continue
+		}
+		pages[filepath.ToSlash(outPath)] = refs
+	}
+	exists := func(target string) (string, bool) {
+		if _, ok := buildPlan.Outputs[filepath.FromSlash(target)]; ok {
+			return target, true
+		}
+		// Link to directory without trailing slash
+		index := path.Join(target, "index.html")
+		if _, ok := buildPlan.Outputs[filepath.FromSlash(index)]; ok {
+			return index, true
+		}
+		return target, false
+	}
+	broken := make([]BrokenLink, 0)
+	for page, refs := range pages {
+		source := buildPlan.Outputs[filepath.FromSlash(page)]
+		for _, link := range refs.links {
+			target, fragment, ok := resolveLink(page, link.url)
+			if !ok {
+				continue
+			}
+			if unescaped, err := url.PathUnescape(target); err == nil {
+				target = unescaped
+			}
+			target, found := exists(target)
+			if !found {
+				reason := "missing page"
+				if link.image {
+					reason = "missing image"
+				}
+				broken = append(broken, BrokenLink{source, page, link.url, reason})
+				continue
+			}
+			if fragment == "" {
+				continue
+			}
+			if targetRefs, ok := pages[target]; ok && !targetRefs.ids[fragment] {
+				broken = append(broken, BrokenLink{source, page, link.url, "missing anchor #" + fragment})
+			}
+		}
+	}
+	sort.Slice(broken, func(i, j int) bool {
+		if broken[i].Source != broken[j].Source {
+			return broken[i].Source < broken[j].Source
+		}
+		if broken[i].Page != broken[j].Page {
+			return broken[i].Page < broken[j].Page
+		}
+		return broken[i].Link < broken[j].Link
+	})
+	return broken
+}
+
+// Print broken links grouped by source file
+func ReportBrokenLinks(broken []BrokenLink) {
+	if len(broken) == 0 {
+		Log("No broken links found")
+		return
+	}
+	lastSource := ""
+	for _, item := range broken {
+		if item.Source != lastSource {
+			Warn(item.Source)
+			lastSource = item.Source
+		}
+		Log(INDENT + item.Link + " (" + item.Reason + " in " + item.Page + ")")
+	}
+	Error(fmt.Sprintf("Found %d broken links", len(broken)))
+}
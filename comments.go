@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Comment system of articles, settings of the selected provider are used
+type CommentsConfig struct {
+	// disqus, giscus, utterances, gitalk, waline, twikoo or iframe
+	Provider   string
+	Disqus     DisqusConfig
+	Giscus     GiscusConfig
+	Utterances UtterancesConfig
+	Gitalk     GitalkConfig
+	Waline     WalineConfig
+	Twikoo     TwikooConfig
+	Iframe     IframeConfig
+}
+
+type DisqusConfig struct {
+	Shortname string
+}
+
+type GiscusConfig struct {
+	Repo       string
+	RepoId     string
+	Category   string
+	CategoryId string
+	// Mapping between page and discussion, default pathname
+	Mapping string
+	Theme   string
+	Lang    string
+}
+
+type UtterancesConfig struct {
+	Repo string
+	// Mapping between page and issue, default pathname
+	IssueTerm string
+	Label     string
+	Theme     string
+}
+
+type GitalkConfig struct {
+	ClientId     string
+	ClientSecret string
+	Repo         string
+	Owner        string
+	Admin        []string
+	Lang         string
+}
+
+type WalineConfig struct {
+	ServerUrl string
+	Lang      string
+}
+
+type TwikooConfig struct {
+	// Environment id of Tencent Cloud or url of self-hosted server
+	EnvId  string
+	Region string
+	Lang   string
+}
+
+type IframeConfig struct {
+	// Url of comments page, {url} and {id} are replaced by url and path of article
+	Src    string
+	Height int
+}
+
+// Data of comments template
+type CommentsPage struct {
+	CommentsConfig
+	// Absolute url of article
+	Url string
+	// Path of article identifying its thread
+	Id string
+	// Site language, such as zh-CN
+	Lang string
+}
+
+const COMMENTS_PARTIAL = "comments"
+
+// Default comments partial, themes can override it by _comments.html
+const DEFAULT_COMMENTS_PARTIAL = `{{comments .}}`
+
+var commentsTpls = map[string]string{
+	"disqus": `<div id="disqus_thread"></div>
+<script>
+var disqus_config = function () { this.page.url = {{.Url}}; this.page.identifier = {{.Id}}; };
+(function () { var s = document.createElement('script'); s.src = 'https://' + {{.Disqus.Shortname}} + '.disqus.com/embed.js'; s.setAttribute('data-timestamp', +new Date()); (document.head || document.body).appendChild(s); })();
+</script>`,
+	"giscus":     `<script src="https://giscus.app/client.js" data-repo="{{.Giscus.Repo}}" data-repo-id="{{.Giscus.RepoId}}" data-category="{{.Giscus.Category}}" data-category-id="{{.Giscus.CategoryId}}" data-mapping="{{or .Giscus.Mapping "pathname"}}" data-reactions-enabled="1" data-emit-metadata="0" data-input-position="bottom" data-theme="{{or .Giscus.Theme "preferred_color_scheme"}}" data-lang="{{or .Giscus.Lang .Lang}}" data-loading="lazy" crossorigin="anonymous" async></script>`,
+	"utterances": `<script src="https://utteranc.es/client.js" repo="{{.Utterances.Repo}}" issue-term="{{or .Utterances.IssueTerm "pathname"}}"{{if .Utterances.Label}} label="{{.Utterances.Label}}"{{end}} theme="{{or .Utterances.Theme "github-light"}}" crossorigin="anonymous" async></script>`,
+	"gitalk": `<link rel="stylesheet" href="https://unpkg.com/gitalk/dist/gitalk.css">
+<div id="gitalk-container"></div>
+<script src="https://unpkg.com/gitalk/dist/gitalk.min.js"></script>
+<script>
+new Gitalk({ clientID: {{.Gitalk.ClientId}}, clientSecret: {{.Gitalk.ClientSecret}}, repo: {{.Gitalk.Repo}}, owner: {{.Gitalk.Owner}}, admin: {{.Gitalk.Admin}}, id: {{gitalkId .Id}}, language: {{or .Gitalk.Lang .Lang}} }).render('gitalk-container');
+</script>`,
+	"waline": `<link rel="stylesheet" href="https://unpkg.com/@waline/client@v3/dist/waline.css">
+<div id="waline"></div>
+<script type="module">
+import { init } from 'https://unpkg.com/@waline/client@v3/dist/waline.js';
+init({ el: '#waline', serverURL: {{.Waline.ServerUrl}}, path: {{.Id}}, lang: {{or .Waline.Lang .Lang}} });
+</script>`,
+	"twikoo": `<div id="tcomment"></div>
+<script src="https://cdn.jsdelivr.net/npm/twikoo@1.6.39/dist/twikoo.all.min.js"></script>
+<script>
+twikoo.init({ envId: {{.Twikoo.EnvId}}, el: '#tcomment', path: {{.Id}}{{if .Twikoo.Region}}, region: {{.Twikoo.Region}}{{end}}, lang: {{or .Twikoo.Lang .Lang}} });
+</script>`,
+	"iframe": `<iframe class="comments" src="{{iframeSrc .}}" width="100%" height="{{or .Iframe.Height 400}}" frameborder="0" loading="lazy"></iframe>`,
+}
+
+var commentsFuncs = template.FuncMap{
+	// Gitalk labels are limited to 50 characters
+	"gitalkId": func(id string) string {
+		hash := md5.Sum([]byte(id))
+		return hex.EncodeToString(hash[:])
+	},
+	"iframeSrc": func(page CommentsPage) string {
+		return strings.NewReplacer("{url}", url.QueryEscape(page.Url), "{id}", url.QueryEscape(page.Id)).Replace(page.Iframe.Src)
+	},
+}
+
+// Provider templates compiled once
+var commentsTpl = func() *template.Template {
+	tpl := template.New(COMMENTS_PARTIAL).Funcs(commentsFuncs)
+	for provider, text := range commentsTpls {
+		template.Must(tpl.New(provider).Parse(text))
+	}
+	return tpl
+}()
+
+// Check settings of comments provider, Site.Comment is the Disqus shortname of old configs
+func ValidateComments(config *GlobalConfig) error {
+	comments := &config.Comments
+	if comments.Provider == "" && config.Site.Comment != "" {
+		comments.Provider = "disqus"
+		comments.Disqus.Shortname = config.Site.Comment
+	}
+	comments.Provider = strings.ToLower(comments.Provider)
+	if comments.Provider == "" {
+		return nil
+	}
+	required := map[string]map[string]string{
+		"disqus":     {"shortname": comments.Disqus.Shortname},
+		"giscus":     {"repo": comments.Giscus.Repo, "repoid": comments.Giscus.RepoId, "categoryid": comments.Giscus.CategoryId},
+		"utterances": {"repo": comments.Utterances.Repo},
+		"gitalk":     {"clientid": comments.Gitalk.ClientId, "clientsecret": comments.Gitalk.ClientSecret, "repo": comments.Gitalk.Repo, "owner": comments.Gitalk.Owner},
+		"waline":     {"serverurl": comments.Waline.ServerUrl},
+		"twikoo":     {"envid": comments.Twikoo.EnvId},
+		"iframe":     {"src": comments.Iframe.Src},
+	}
+	fields, ok := required[comments.Provider]
+	if !ok {
+		return errors.New("Unknown comments provider: " + comments.Provider + ", available: " + strings.Join(configEnums["comments.provider"], ", "))
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	for _, field := range names {
+		if fields[field] == "" {
+			return errors.New("Missing comments." + comments.Provider + "." + field + " in config.yml")
+		}
+	}
+	return nil
+}
+
+// Get language code like zh-CN from site language like zh-cn
+func commentsLang(lang string) string {
+	parts := strings.SplitN(lang, "-", 2)
+	if len(parts) == 2 {
+		return parts[0] + "-" + strings.ToUpper(parts[1])
+	}
+	return lang
+}
+
+// Render comments of article by provider, empty if comments are disabled
+func (ctx FuncContext) Comments(data interface{}) template.HTML {
+	var article Article
+	switch page := data.(type) {
+	case RenderArticle:
+		article = page.Article
+	case Article:
+		article = page
+	default:
+		return ""
+	}
+	comments := ctx.global.Comments
+	if comments.Provider == "" || (article.EnableComments != nil && !*article.EnableComments) {
+		return ""
+	}
+	page := CommentsPage{
+		CommentsConfig: comments,
+		Url:            ctx.global.Site.Url + "/" + article.Link,
+		Id:             ctx.global.Site.Root + "/" + article.Link,
+		Lang:           commentsLang(ctx.global.Site.Lang),
+	}
+	var out bytes.Buffer
+	if err := commentsTpl.ExecuteTemplate(&out, comments.Provider, page); err != nil {
+		BuildFatal(NewTemplateError(err))
+	}
+	return template.HTML(out.String())
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+type CompressConfig struct {
+	Gzip   bool
+	Brotli bool
+	// Minimal file size in bytes to compress
+	Threshold int
+	// File extensions to compress
+	Types []string
+}
+
+const DEFAULT_COMPRESS_THRESHOLD = 1024
+
+var defaultCompressTypes = []string{".html", ".xml", ".json", ".css", ".js"}
+
+// Precompressed file extensions by preference
+var compressEncodings = []struct {
+	Name string
+	Ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Write compressed file by encoder
+func writeCompressed(path string, ext string, encode func(io.Writer) io.WriteCloser) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	dest, err := os.Create(path + ext)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	writer := encode(dest)
+	_, err = io.Copy(writer, source)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// Partial file is not kept
+		dest.Close()
+		os.Remove(path + ext)
+	}
+	return err
+}
+
+// Write .gz and .br files alongside compressible outputs, return paths of written files
+func CompressOutputs(outPaths []string) []string {
+	config := globalConfig.Build.Compress
+	written := make([]string, 0)
+	if !config.Gzip && !config.Brotli {
+		return written
+	}
+	threshold := config.Threshold
+	if threshold <= 0 {
+		threshold = DEFAULT_COMPRESS_THRESHOLD
+	}
+	types := config.Types
+	if len(types) == 0 {
+		types = defaultCompressTypes
+	}
+	typeMap := make(map[string]bool)
+	for _, ext := range types {
+		typeMap["."+strings.TrimPrefix(strings.ToLower(ext), ".")] = true
+	}
+	var compressWg sync.WaitGroup
+	var mutex sync.Mutex
+	count := 0
+	for _, outPath := range outPaths {
+		if !typeMap[strings.ToLower(filepath.Ext(outPath))] {
+			continue
+		}
+		fullPath := filepath.Join(publicPath, outPath)
+		info, err := os.Stat(fullPath)
+		if err != nil || info.Size() < int64(threshold) {
+			continue
+		}
+		compressWg.Add(1)
+		outPath := outPath
+		go func() {
+			defer compressWg.Done()
+			if config.Gzip {
+				err := writeCompressed(fullPath, ".gz", func(w io.Writer) io.WriteCloser {
+					writer, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+					return writer
+				})
+				if err != nil {
+					Error(err.Error())
+				} else {
+					mutex.Lock()
+					written = append(written, outPath+".gz")
+					mutex.Unlock()
+				}
+			}
+			if config.Brotli {
+				err := writeCompressed(fullPath, ".br", func(w io.Writer) io.WriteCloser {
+					return brotli.NewWriterLevel(w, 9)
+				})
+				if err != nil {
+					Error(err.Error())
+				} else {
+					mutex.Lock()
+					written = append(written, outPath+".br")
+					mutex.Unlock()
+				}
+			}
+			mutex.Lock()
+			count++
+			mutex.Unlock()
+		}()
+	}
+	compressWg.Wait()
+	Log(fmt.Sprintf("Compressed %d files", count))
+	sort.Strings(written)
+	return written
+}
+
+// Check if encoding is accepted by Accept-Encoding header
+func acceptsEncoding(header string, encoding string) bool {
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name != encoding && name != "*" {
+			continue
+		}
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Find precompressed file accepted by Accept-Encoding header
+func findCompressed(header string, filePath string, info os.FileInfo) (string, os.FileInfo, string) {
+	for _, encoding := range compressEncodings {
+		if !acceptsEncoding(header, encoding.Name) {
+			continue
+		}
+		// Skip compressed file older than the original one
+		compressedInfo, err := os.Stat(filePath + encoding.Ext)
+		if err != nil || compressedInfo.ModTime().Before(info.ModTime()) {
+			continue
+		}
+		return filePath + encoding.Ext, compressedInfo, encoding.Name
+	}
+	return filePath, info, ""
+}
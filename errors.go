@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Error with source location shown in preview
+type BuildError struct {
+	File    string        `json:"file,omitempty"`
+	Line    int           `json:"line,omitempty"`
+	Message string        `json:"message"`
+	Excerpt []ExcerptLine `json:"excerpt,omitempty"`
+}
+
+// Source line around error
+type ExcerptLine struct {
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	Current bool   `json:"current,omitempty"`
+}
+
+// Error stopped building in preview mode
+type buildFailure struct {
+	err error
+}
+
+// Errors of build goroutines
+type BuildErrors struct {
+	sync.Mutex
+	errors []error
+}
+
+// Source file of compiled template by name
+type TemplatePaths struct {
+	sync.RWMutex
+	paths map[string]string
+}
+
+const EXCERPT_LINES = 3
+
+var buildErrors BuildErrors
+
+// Server keeps running on errors once started
+var serverStarted bool
+var templatePaths = TemplatePaths{paths: make(map[string]string)}
+
+// Match "template: name:line:col: message"
+var templateErrorRegexp = regexp.MustCompile(`^template: ([^:]+):(\d+)(?::\d+)?: (.*)$`)
+
+// Match "yaml: line 3: message"
+var yamlErrorRegexp = regexp.MustCompile(`line (\d+)`)
+
+func (err *BuildError) Error() string {
+	if err.File == "" {
+		return err.Message
+	}
+	if err.Line == 0 {
+		return err.File + ": " + err.Message
+	}
+	return err.File + ":" + strconv.Itoa(err.Line) + ": " + err.Message
+}
+
+// Read lines around error line of source file
+func (err *BuildError) ReadExcerpt() {
+	if err.File == "" || err.Line == 0 {
+		return
+	}
+	data, readErr := os.ReadFile(err.File)
+	if readErr != nil {
+		return
+	}
+	lines := strings.Split(string(data), "\n")
+	err.Excerpt = make([]ExcerptLine, 0)
+	for i := err.Line - EXCERPT_LINES; i <= err.Line+EXCERPT_LINES; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		err.Excerpt = append(err.Excerpt, ExcerptLine{
+			Line:    i,
+			Text:    strings.TrimRight(lines[i-1], "\r"),
+			Current: i == err.Line,
+		})
+	}
+}
+
+// Convert error to BuildError
+func AsBuildError(err error) *BuildError {
+	var buildErr *BuildError
+	if errors.As(err, &buildErr) {
+		return buildErr
+	}
+	return &BuildError{Message: err.Error()}
+}
+
+func (paths *TemplatePaths) Set(name string, path string) {
+	paths.Lock()
+	defer paths.Unlock()
+	paths.paths[name] = path
+}
+
+func (paths *TemplatePaths) Get(name string) string {
+	paths.RLock()
+	defer paths.RUnlock()
+	return paths.paths[name]
+}
+
+// Locate template parse or execute error in template file
+func NewTemplateError(err error) *BuildError {
+	match := templateErrorRegexp.FindStringSubmatch(err.Error())
+	if match == nil {
+		return &BuildError{Message: err.Error()}
+	}
+	line, _ := strconv.Atoi(match[2])
+	return &BuildError{File: templatePaths.Get(match[1]), Line: line, Message: match[3]}
+}
+
+// Locate yaml error in file, lineOffset is the line number before yaml content
+func NewYamlError(path string, lineOffset int, err error) *BuildError {
+	buildErr := &BuildError{File: path, Message: err.Error()}
+	if match := yamlErrorRegexp.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		buildErr.Line = line + lineOffset
+	}
+	return buildErr
+}
+
+func (errs *BuildErrors) Add(err error) {
+	errs.Lock()
+	defer errs.Unlock()
+	errs.errors = append(errs.errors, err)
+}
+
+func (errs *BuildErrors) Reset() {
+	errs.Lock()
+	defer errs.Unlock()
+	errs.errors = nil
+}
+
+// Get the first error
+func (errs *BuildErrors) First() error {
+	errs.Lock()
+	defer errs.Unlock()
+	if len(errs.errors) == 0 {
+		return nil
+	}
+	return errs.errors[0]
+}
+
+// Stop building, exit in build mode and stop current work in preview or serve mode
+func BuildFatal(info interface{}) {
+	if globalConfig == nil || !(globalConfig.Develop || serverStarted) {
+		Fatal(info)
+	}
+	err, ok := info.(error)
+	if !ok {
+		err = errors.New(fmt.Sprint(info))
+	}
+	panic(buildFailure{err})
+}
+
+// Report error which does not stop building, the build still fails
+func ReportBuildError(err error) {
+	// Preview prints the error when the build fails
+	if globalConfig == nil || !globalConfig.Develop {
+		Error(err.Error())
+	}
+	buildErrors.Add(err)
+}
+
+// Record error stopped build goroutine, must be deferred
+func RecoverBuildError() {
+	if r := recover(); r != nil {
+		failure, ok := r.(buildFailure)
+		if !ok {
+			panic(r)
+		}
+		buildErrors.Add(failure.err)
+	}
+}
+
+// Run fn and return error stopped it in preview mode
+func CatchBuildError(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure, ok := r.(buildFailure)
+			if !ok {
+				panic(r)
+			}
+			err = failure.err
+		}
+	}()
+	fn()
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/facebookgo/symwalk"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Article in export, config uses the keys of front matter
+type ExportArticle struct {
+	// Markdown file relative to source folder
+	Path string `json:"path"`
+	// Normalized markdown file in zip archive
+	File string `json:"file,omitempty"`
+	Link string `json:"link"`
+	Url  string `json:"url"`
+	// Dates resolved from front matter or git history
+	Date     string                 `json:"date,omitempty"`
+	Update   string                 `json:"update,omitempty"`
+	Config   map[string]interface{} `json:"config"`
+	Markdown string                 `json:"markdown,omitempty"`
+}
+
+// Site content exported by ink export
+type ExportDocument struct {
+	Version  string                 `json:"version"`
+	Site     map[string]interface{} `json:"site"`
+	Authors  map[string]interface{} `json:"authors"`
+	Articles []ExportArticle        `json:"articles"`
+}
+
+const (
+	EXPORT_SITE_FILE   = "site.json"
+	EXPORT_CONTENT_DIR = "content"
+	EXPORT_STATIC_DIR  = "static"
+)
+
+// Convert config struct to map with the keys of config.yml
+func configMap(config interface{}) map[string]interface{} {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		Fatal(err.Error())
+	}
+	result := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		Fatal(err.Error())
+	}
+	return result
+}
+
+// Remove empty values from config map
+func compactMap(config map[string]interface{}) map[string]interface{} {
+	for key, value := range config {
+		switch v := value.(type) {
+		case nil:
+			delete(config, key)
+		case string:
+			if v == "" {
+				delete(config, key)
+			}
+		case bool:
+			if !v {
+				delete(config, key)
+			}
+		case []interface{}:
+			if len(v) == 0 {
+				delete(config, key)
+			}
+		case map[string]interface{}:
+			if len(compactMap(v)) == 0 {
+				delete(config, key)
+			}
+		}
+	}
+	return config
+}
+
+// Split markdown file into front matter and body the same way as ParseArticleConfig
+func splitArticle(markdownPath string) (*ArticleConfig, string) {
+	data, err := os.ReadFile(markdownPath)
+	if err != nil {
+		Fatal(err.Error())
+	}
+	parts := strings.SplitN(string(data), CONFIG_SPLIT, 2)
+	var config ArticleConfig
+	if err := yaml.Unmarshal([]byte(ReplaceRootFlag(parts[0])), &config); err != nil {
+		Fatal(NewYamlError(markdownPath, 0, err))
+	}
+	if config.Type == "" {
+		config.Type = "post"
+	}
+	markdown := ""
+	if len(parts) > 1 {
+		markdown = strings.TrimLeft(parts[1], "\r\n")
+	}
+	return &config, markdown
+}
+
+// Collect articles of source folder sorted by path
+func ExportArticles() []ExportArticle {
+	articles := make([]ExportArticle, 0)
+	symwalk.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || strings.ToLower(filepath.Ext(path)) != ".md" {
+			return nil
+		}
+		article := ParseArticle(path)
+		if article == nil {
+			return nil
+		}
+		config, markdown := splitArticle(path)
+		relPath, _ := filepath.Rel(sourcePath, path)
+		exported := ExportArticle{
+			Path:     filepath.ToSlash(relPath),
+			Link:     article.Link,
+			Url:      globalConfig.Site.Url + "/" + article.Link,
+			Config:   configMap(config),
+			Markdown: markdown,
+		}
+		if !article.Time.IsZero() {
+			exported.Date = article.Time.Format(time.RFC3339)
+		}
+		if !article.MTime.IsZero() {
+			exported.Update = article.MTime.Format(time.RFC3339)
+		}
+		articles = append(articles, exported)
+		return nil
+	})
+	sort.Slice(articles, func(i, j int) bool {
+		return articles[i].Path < articles[j].Path
+	})
+	return articles
+}
+
+// Encode export document as indented JSON
+func writeExportJSON(writer io.Writer, document ExportDocument) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", INDENT)
+	return encoder.Encode(document)
+}
+
+// Normalized markdown, front matter has no empty fields and dates are resolved
+func normalizedMarkdown(article ExportArticle) ([]byte, error) {
+	config := compactMap(article.Config)
+	for key, value := range map[string]string{"date": article.Date, "update": article.Update} {
+		if date, err := time.Parse(time.RFC3339, value); err == nil {
+			config[key] = date.Format(DATE_FORMAT_WITH_TIMEZONE)
+		}
+	}
+	// Fields are written in the order of ArticleConfig
+	var fields yaml.Node
+	if err := fields.Encode(ArticleConfig{}); err != nil {
+		return nil, err
+	}
+	front := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < len(fields.Content); i += 2 {
+		key := fields.Content[i].Value
+		value, ok := config[key]
+		if !ok {
+			continue
+		}
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(value); err != nil {
+			return nil, err
+		}
+		front.Content = append(front.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, valueNode)
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(4)
+	if err := encoder.Encode(front); err != nil {
+		return nil, err
+	}
+	out.WriteString("\n" + CONFIG_SPLIT + "\n\n" + article.Markdown)
+	return out.Bytes(), nil
+}
+
+// Add file to zip archive
+func addZipFile(archive *zip.Writer, name string, data []byte, modified time.Time) {
+	writer, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     filepath.ToSlash(name),
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		Fatal(err.Error())
+	}
+	if _, err := writer.Write(data); err != nil {
+		Fatal(err.Error())
+	}
+}
+
+// Read file added to zip archive
+func addZipSource(archive *zip.Writer, name string, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		Fatal(err.Error())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		Fatal(err.Error())
+	}
+	addZipFile(archive, name, data, info.ModTime())
+}
+
+// Write zip archive of normalized markdown, source assets and copied static files
+func writeExportZip(writer io.Writer, document ExportDocument) {
+	archive := zip.NewWriter(writer)
+	now := time.Now()
+	for i, article := range document.Articles {
+		data, err := normalizedMarkdown(article)
+		if err != nil {
+			Fatal(err.Error())
+		}
+		document.Articles[i].File = EXPORT_CONTENT_DIR + "/" + article.Path
+		document.Articles[i].Markdown = ""
+		addZipFile(archive, document.Articles[i].File, data, now)
+	}
+	// Assets next to articles keep their relative links
+	symwalk.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || strings.ToLower(filepath.Ext(path)) == ".md" {
+			return nil
+		}
+		relPath, _ := filepath.Rel(sourcePath, path)
+		addZipSource(archive, filepath.Join(EXPORT_CONTENT_DIR, relPath), path)
+		return nil
+	})
+	for _, item := range NewBuildPlan().AddCopy(globalConfig.Build.Copy) {
+		relPath, _ := filepath.Rel(publicPath, item.Target)
+		addZipSource(archive, filepath.Join(EXPORT_STATIC_DIR, relPath), item.Source)
+	}
+	var site bytes.Buffer
+	if err := writeExportJSON(&site, document); err != nil {
+		Fatal(err.Error())
+	}
+	addZipFile(archive, EXPORT_SITE_FILE, site.Bytes(), now)
+	if err := archive.Close(); err != nil {
+		Fatal(err.Error())
+	}
+}
+
+// Export articles, site config and authors as JSON or zip archive
+func Export(c *cli.Context) {
+	output := c.String("output")
+	if output == "-" {
+		// Keep stdout for JSON
+		logOutput = os.Stderr
+	}
+	root := c.String("root")
+	if root == "" {
+		root = FindRootPath()
+	}
+	ParseGlobalConfigWrap(root, false)
+	if globalConfig == nil {
+		Fatal("Parse config.yml failed, please specify a valid root path")
+	}
+	format := strings.ToLower(c.String("format"))
+	if format == "" {
+		format = "json"
+		if strings.ToLower(filepath.Ext(output)) == ".zip" {
+			format = "zip"
+		}
+	}
+	if format != "json" && format != "zip" {
+		Fatal("Unknown export format: " + format + ", available: json, zip")
+	}
+	if output == "" {
+		output = "export." + format
+	}
+	if output == "-" && format == "zip" {
+		Fatal("Zip archive can not be written to stdout")
+	}
+	InitArticleParser()
+	// Authors are resolved as in ParseArticle
+	authors := make(map[string]AuthorConfig)
+	for id, author := range globalConfig.Authors {
+		author.Id = id
+		author.Avatar = ReplaceRootFlag(author.Avatar)
+		authors[id] = author
+	}
+	document := ExportDocument{
+		Version:  VERSION,
+		Site:     configMap(globalConfig.Site),
+		Authors:  configMap(authors),
+		Articles: ExportArticles(),
+	}
+	var writer io.Writer = os.Stdout
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			Fatal(err.Error())
+		}
+		defer file.Close()
+		writer = file
+	}
+	if format == "zip" {
+		writeExportZip(writer, document)
+	} else if err := writeExportJSON(writer, document); err != nil {
+		Fatal(err.Error())
+	}
+	if output != "-" {
+		Log("Exported " + strconv.Itoa(len(document.Articles)) + " articles to " + output)
+	}
+}
@@ -18,6 +18,8 @@ func (ctx FuncContext) FuncMap() template.FuncMap {
 	return template.FuncMap{
 		"i18n":     ctx.I18n,
 		"readFile": ctx.ReadFile,
+		"seo":      ctx.Seo,
+		"comments": ctx.Comments,
 	}
 }
 
@@ -26,6 +28,13 @@ func (ctx FuncContext) I18n(val string) string {
 }
 
 func (ctx FuncContext) ReadFile(path string) template.HTML {
-	bytes, _ := os.ReadFile(filepath.Join(ctx.currentCwd, path))
+	fullPath := filepath.Join(ctx.currentCwd, path)
+	// Theme files follow the template lookup order
+	if ctx.currentCwd == ctx.themePath {
+		if found := LookupTemplate(path); found != "" {
+			fullPath = found
+		}
+	}
+	bytes, _ := os.ReadFile(fullPath)
 	return template.HTML(bytes)
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Git history of a source file
+type GitInfo struct {
+	// Last commit touching the file
+	Hash        string
+	AbbrevHash  string
+	Author      string
+	AuthorEmail string
+	Subject     string
+	LastMod     time.Time
+	// First commit adding the file
+	Created time.Time
+}
+
+// Git history of source files by absolute path, nil if not enabled
+var gitHistory map[string]*GitInfo
+
+const (
+	gitRecordSplit = "\x1e"
+	gitFieldSplit  = "\x1f"
+)
+
+// Read history of files in source folder with one git command
+func LoadGitHistory(root string) map[string]*GitInfo {
+	topLevel, err := runGit(root, "rev-parse", "--show-toplevel")
+	if err != nil {
+		Warn("Git info is disabled: " + err.Error())
+		return nil
+	}
+	// Source folder relative to repository root, history of other files is skipped
+	sourceDir, _ := filepath.Abs(filepath.Join(root, "source"))
+	if realPath, err := filepath.EvalSymlinks(sourceDir); err == nil {
+		sourceDir = realPath
+	}
+	relSource, err := filepath.Rel(topLevel, sourceDir)
+	if err != nil || relSource == ".." || strings.HasPrefix(relSource, ".."+string(filepath.Separator)) {
+		Warn("Git info is disabled: source folder is not in the repository " + topLevel)
+		return nil
+	}
+	format := "--format=" + gitRecordSplit + strings.Join([]string{"%H", "%h", "%an", "%ae", "%at", "%s"}, gitFieldSplit)
+	out, err := runGit(root, "-c", "core.quotePath=false", "log", "--name-only", "--no-renames", format, "--", ":(top)"+filepath.ToSlash(relSource))
+	if err != nil {
+		Warn("Git info is disabled: " + err.Error())
+		return nil
+	}
+	history := make(map[string]*GitInfo)
+	// Commits are listed from newest to oldest
+	for _, record := range strings.Split(out, gitRecordSplit) {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		fields := strings.Split(lines[0], gitFieldSplit)
+		if len(fields) != 6 {
+			continue
+		}
+		timestamp, _ := strconv.ParseInt(fields[4], 10, 64)
+		commitTime := time.Unix(timestamp, 0)
+		for _, name := range lines[1:] {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			filePath := filepath.Join(topLevel, filepath.FromSlash(name))
+			if info, ok := history[filePath]; ok {
+				info.Created = commitTime
+				continue
+			}
+			history[filePath] = &GitInfo{
+				Hash:        fields[0],
+				AbbrevHash:  fields[1],
+				Author:      fields[2],
+				AuthorEmail: fields[3],
+				Subject:     fields[5],
+				LastMod:     commitTime,
+				Created:     commitTime,
+			}
+		}
+	}
+	return history
+}
+
+// Get git history of file, nil if it is not committed
+func GitInfoOf(path string) *GitInfo {
+	if gitHistory == nil {
+		return nil
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	// Git reports real paths
+	if realPath, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = realPath
+	}
+	return gitHistory[absPath]
+}
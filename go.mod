@@ -5,13 +5,17 @@ go 1.18
 require (
 	github.com/BurntSushi/toml v1.4.0
 	github.com/InkProject/ink.go v0.0.0-20160120061933-86de6d066e8d
+	github.com/andybalholm/brotli v1.1.0
 	github.com/facebookgo/symwalk v0.0.0-20150726040526-42004b9f3222
 	github.com/fsnotify/fsnotify v1.7.0
 	github.com/gomarkdown/markdown v0.0.0-20240730141124-034f12af3bf6
 	github.com/gorilla/feeds v1.2.0
 	github.com/gorilla/websocket v1.5.3
 	github.com/snabb/sitemap v1.0.4
+	github.com/tdewolff/minify/v2 v2.20.37
 	github.com/urfave/cli/v2 v2.27.4
+	golang.org/x/image v0.19.0
+	golang.org/x/net v0.21.0
 	golang.org/x/text v0.17.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -25,6 +29,7 @@ require (
 	github.com/facebookgo/testname v0.0.0-20150612200628-5443337c3a12 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/snabb/diagio v1.0.4 // indirect
+	github.com/tdewolff/parse/v2 v2.7.15 // indirect
 	github.com/xrash/smetrics v0.0.0-20240521201337-686a1a2994c1 // indirect
 	golang.org/x/sys v0.18.0 // indirect
 )
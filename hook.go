@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+)
+
+// Data of render hook template
+type RenderHook struct {
+	// Url of link or image
+	Destination string
+	Title       string
+	// Rendered content of link or heading, alt of image
+	Text      template.HTML
+	PlainText string
+	// Level and id of heading
+	Level  int
+	Anchor string
+	// Language and content of code block
+	Lang       string
+	Code       string
+	Attributes map[string]string
+	Site       SiteConfig
+}
+
+// Templates of render hooks in theme, such as render-image.html
+const (
+	RENDER_IMAGE     = "render-image"
+	RENDER_LINK      = "render-link"
+	RENDER_HEADING   = "render-heading"
+	RENDER_CODEBLOCK = "render-codeblock"
+)
+
+// Loading image shown until the image is lazy loaded by jQuery-unveil
+const LAZY_LOAD_PLACEHOLDER = "data:image/gif;base64,R0lGODlhGAAYAPQAAP///wAAAM7Ozvr6+uDg4LCwsOjo6I6OjsjIyJycnNjY2KioqMDAwPLy8nd3d4aGhri4uGlpaQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAACH5BAkHAAAAIf4aQ3JlYXRlZCB3aXRoIGFqYXhsb2FkLmluZm8AIf8LTkVUU0NBUEUyLjADAQAAACwAAAAAGAAYAAAFriAgjiQAQWVaDgr5POSgkoTDjFE0NoQ8iw8HQZQTDQjDn4jhSABhAAOhoTqSDg7qSUQwxEaEwwFhXHhHgzOA1xshxAnfTzotGRaHglJqkJcaVEqCgyoCBQkJBQKDDXQGDYaIioyOgYSXA36XIgYMBWRzXZoKBQUMmil0lgalLSIClgBpO0g+s26nUWddXyoEDIsACq5SsTMMDIECwUdJPw0Mzsu0qHYkw72bBmozIQAh+QQJBwAAACwAAAAAGAAYAAAFsCAgjiTAMGVaDgR5HKQwqKNxIKPjjFCk0KNXC6ATKSI7oAhxWIhezwhENTCQEoeGCdWIPEgzESGxEIgGBWstEW4QCGGAIJEoxGmGt5ZkgCRQQHkGd2CESoeIIwoMBQUMP4cNeQQGDYuNj4iSb5WJnmeGng0CDGaBlIQEJziHk3sABidDAHBgagButSKvAAoyuHuUYHgCkAZqebw0AgLBQyyzNKO3byNuoSS8x8OfwIchACH5BAkHAAAALAAAAAAYABgAAAW4ICCOJIAgZVoOBJkkpDKoo5EI43GMjNPSokXCINKJCI4HcCRIQEQvqIOhGhBHhUTDhGo4diOZyFAoKEQDxra2mAEgjghOpCgz3LTBIxJ5kgwMBShACREHZ1V4Kg1rS44pBAgMDAg/Sw0GBAQGDZGTlY+YmpyPpSQDiqYiDQoCliqZBqkGAgKIS5kEjQ21VwCyp76dBHiNvz+MR74AqSOdVwbQuo+abppo10ssjdkAnc0rf8vgl8YqIQAh+QQJBwAAACwAAAAAGAAYAAAFrCAgjiQgCGVaDgZZFCQxqKNRKGOSjMjR0qLXTyciHA7AkaLACMIAiwOC1iAxCrMToHHYjWQiA4NBEA0Q1RpWxHg4cMXxNDk4OBxNUkPAQAEXDgllKgMzQA1pSYopBgonCj9JEA8REQ8QjY+RQJOVl4ugoYssBJuMpYYjDQSliwasiQOwNakALKqsqbWvIohFm7V6rQAGP6+JQLlFg7KDQLKJrLjBKbvAor3IKiEAIfkECQcAAAAsAAAAABgAGAAABbUgII4koChlmhokw5DEoI4NQ4xFMQoJO4uuhignMiQWvxGBIQC+AJBEUyUcIRiyE6CR0CllW4HABxBURTUw4nC4FcWo5CDBRpQaCoF7VjgsyCUDYDMNZ0mHdwYEBAaGMwwHDg4HDA2KjI4qkJKUiJ6faJkiA4qAKQkRB3E0i6YpAw8RERAjA4tnBoMApCMQDhFTuySKoSKMJAq6rD4GzASiJYtgi6PUcs9Kew0xh7rNJMqIhYchACH5BAkHAAAALAAAAAAYABgAAAW0ICCOJEAQZZo2JIKQxqCOjWCMDDMqxT2LAgELkBMZCoXfyCBQiFwiRsGpku0EshNgUNAtrYPT0GQVNRBWwSKBMp98P24iISgNDAS4ipGA6JUpA2WAhDR4eWM/CAkHBwkIDYcGiTOLjY+FmZkNlCN3eUoLDmwlDW+AAwcODl5bYl8wCVYMDw5UWzBtnAANEQ8kBIM0oAAGPgcREIQnVloAChEOqARjzgAQEbczg8YkWJq8nSUhACH5BAkHAAAALAAAAAAYABgAAAWtICCOJGAYZZoOpKKQqDoORDMKwkgwtiwSBBYAJ2owGL5RgxBziQQMgkwoMkhNqAEDARPSaiMDFdDIiRSFQowMXE8Z6RdpYHWnEAWGPVkajPmARVZMPUkCBQkJBQINgwaFPoeJi4GVlQ2Qc3VJBQcLV0ptfAMJBwdcIl+FYjALQgimoGNWIhAQZA4HXSpLMQ8PIgkOSHxAQhERPw7ASTSFyCMMDqBTJL8tf3y2fCEAIfkECQcAAAAsAAAAABgAGAAABa8gII4k0DRlmg6kYZCoOg5EDBDEaAi2jLO3nEkgkMEIL4BLpBAkVy3hCTAQKGAznM0AFNFGBAbj2cA9jQixcGZAGgECBu/9HnTp+FGjjezJFAwFBQwKe2Z+KoCChHmNjVMqA21nKQwJEJRlbnUFCQlFXlpeCWcGBUACCwlrdw8RKGImBwktdyMQEQciB7oACwcIeA4RVwAODiIGvHQKERAjxyMIB5QlVSTLYLZ0sW8hACH5BAkHAAAALAAAAAAYABgAAAW0ICCOJNA0ZZoOpGGQrDoOBCoSxNgQsQzgMZyIlvOJdi+AS2SoyXrK4umWPM5wNiV0UDUIBNkdoepTfMkA7thIECiyRtUAGq8fm2O4jIBgMBA1eAZ6Knx+gHaJR4QwdCMKBxEJRggFDGgQEREPjjAMBQUKIwIRDhBDC2QNDDEKoEkDoiMHDigICGkJBS2dDA6TAAnAEAkCdQ8ORQcHTAkLcQQODLPMIgIJaCWxJMIkPIoAt3EhACH5BAkHAAAALAAAAAAYABgAAAWtICCOJNA0ZZoOpGGQrDoOBCoSxNgQsQzgMZyIlvOJdi+AS2SoyXrK4umWHM5wNiV0UN3xdLiqr+mENcWpM9TIbrsBkEck8oC0DQqBQGGIz+t3eXtob0ZTPgNrIwQJDgtGAgwCWSIMDg4HiiUIDAxFAAoODwxDBWINCEGdSTQkCQcoegADBaQ6MggHjwAFBZUFCm0HB0kJCUy9bAYHCCPGIwqmRq0jySMGmj6yRiEAIfkECQcAAAAsAAAAABgAGAAABbIgII4k0DRlmg6kYZCsOg4EKhLE2BCxDOAxnIiW84l2L4BLZKipBopW8XRLDkeCiAMyMvQAA+uON4JEIo+vqukkKQ6RhLHplVGN+LyKcXA4Dgx5DWwGDXx+gIKENnqNdzIDaiMECwcFRgQCCowiCAcHCZIlCgICVgSfCEMMnA0CXaU2YSQFoQAKUQMMqjoyAglcAAyBAAIMRUYLCUkFlybDeAYJryLNk6xGNCTQXY0juHghACH5BAkHAAAALAAAAAAYABgAAAWzICCOJNA0ZVoOAmkY5KCSSgSNBDE2hDyLjohClBMNij8RJHIQvZwEVOpIekRQJyJs5AMoHA+GMbE1lnm9EcPhOHRnhpwUl3AsknHDm5RN+v8qCAkHBwkIfw1xBAYNgoSGiIqMgJQifZUjBhAJYj95ewIJCQV7KYpzBAkLLQADCHOtOpY5PgNlAAykAEUsQ1wzCgWdCIdeArczBQVbDJ0NAqyeBb64nQAGArBTt8R8mLuyPyEAOw=="
+
+// Lazy load images by default
+const DEFAULT_RENDER_IMAGE = `<img src="` + LAZY_LOAD_PLACEHOLDER + `" data-src="{{.Destination}}" alt="{{.PlainText}}"{{with .Title}} title="{{.}}"{{end}}{{.Attrs}} />`
+
+var renderHookTpls *ThemeTpls
+
+var defaultImageTpl = template.Must(template.New(RENDER_IMAGE).Parse(DEFAULT_RENDER_IMAGE))
+
+// Get render hook template of theme, image falls back to the default one
+func renderHookTpl(name string) *template.Template {
+	var tpl *template.Template
+	if renderHookTpls != nil {
+		tpl = renderHookTpls.Get(name)
+	}
+	if tpl == nil && name == RENDER_IMAGE {
+		return defaultImageTpl
+	}
+	return tpl
+}
+
+// Render children of node to html
+func renderChildren(node ast.Node, renderer *html.Renderer) template.HTML {
+	var buf bytes.Buffer
+	for _, child := range node.GetChildren() {
+		ast.WalkFunc(child, func(node ast.Node, entering bool) ast.WalkStatus {
+			return renderer.RenderNode(&buf, node, entering)
+		})
+	}
+	return template.HTML(buf.String())
+}
+
+// Get text of node without markup
+func plainText(node ast.Node) string {
+	var text strings.Builder
+	ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+		if leaf := node.AsLeaf(); leaf != nil && entering {
+			switch node.(type) {
+			case *ast.Text, *ast.Code:
+				text.Write(leaf.Literal)
+			}
+		}
+		return ast.GoToNext
+	})
+	return text.String()
+}
+
+// Get id, class and other attributes of node
+func nodeAttributes(attr *ast.Attribute) map[string]string {
+	attributes := make(map[string]string)
+	if attr == nil {
+		return attributes
+	}
+	if len(attr.ID) > 0 {
+		attributes["id"] = string(attr.ID)
+	}
+	classes := make([]string, 0, len(attr.Classes))
+	for _, class := range attr.Classes {
+		classes = append(classes, string(class))
+	}
+	if len(classes) > 0 {
+		attributes["class"] = strings.Join(classes, " ")
+	}
+	for key, value := range attr.Attrs {
+		attributes[key] = string(value)
+	}
+	return attributes
+}
+
+// Render attributes as the markdown renderer does, names other than letters, digits and -_: are skipped
+func (hook RenderHook) Attrs() template.HTMLAttr {
+	keys := make([]string, 0, len(hook.Attributes))
+	for key := range hook.Attributes {
+		if key != "" && strings.Trim(strings.ToLower(key), "abcdefghijklmnopqrstuvwxyz0123456789-_:") == "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var out strings.Builder
+	for _, key := range keys {
+		out.WriteString(" " + key + `="` + template.HTMLEscapeString(hook.Attributes[key]) + `"`)
+	}
+	return template.HTMLAttr(out.String())
+}
+
+// Render image, link, heading and code block nodes by theme templates
+func RenderHookNode(w io.Writer, node ast.Node, entering bool, renderer *html.Renderer) (ast.WalkStatus, bool) {
+	var name string
+	var data RenderHook
+	switch node := node.(type) {
+	case *ast.Image:
+		name = RENDER_IMAGE
+		data = RenderHook{
+			Destination: string(node.Destination),
+			Title:       string(node.Title),
+			Text:        template.HTML(template.HTMLEscapeString(plainText(node))),
+			PlainText:   plainText(node),
+			Attributes:  nodeAttributes(node.Attribute),
+		}
+	case *ast.Link:
+		// Footnote references are rendered by markdown
+		if node.NoteID != 0 || node.Footnote != nil {
+			return ast.GoToNext, false
+		}
+		name = RENDER_LINK
+		data = RenderHook{
+			Destination: string(node.Destination),
+			Title:       string(node.Title),
+			PlainText:   plainText(node),
+			Attributes:  nodeAttributes(node.Attribute),
+		}
+	case *ast.Heading:
+		if node.IsTitleblock {
+			return ast.GoToNext, false
+		}
+		name = RENDER_HEADING
+		data = RenderHook{
+			Level:      node.Level,
+			Anchor:     node.HeadingID,
+			PlainText:  plainText(node),
+			Attributes: nodeAttributes(node.Attribute),
+		}
+	case *ast.CodeBlock:
+		name = RENDER_CODEBLOCK
+		data = RenderHook{
+			Lang:       strings.TrimSpace(string(node.Info)),
+			Code:       string(node.Literal),
+			Attributes: nodeAttributes(node.Attribute),
+		}
+	default:
+		return ast.GoToNext, false
+	}
+	tpl := renderHookTpl(name)
+	if tpl == nil {
+		return ast.GoToNext, false
+	}
+	// Children are rendered into Text, skip closing of container
+	if !entering {
+		return ast.GoToNext, true
+	}
+	if data.Text == "" && name != RENDER_CODEBLOCK {
+		data.Text = renderChildren(node, renderer)
+	}
+	if globalConfig != nil {
+		data.Site = globalConfig.Site
+	}
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		BuildFatal(NewTemplateError(err))
+	}
+	// Ending newline of template file would add space after inline links
+	w.Write(bytes.TrimRight(out.Bytes(), "\r\n"))
+	return ast.SkipChildren, true
+}
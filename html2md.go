@@ -0,0 +1,237 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Convert html to markdown, elements without markdown syntax are kept as html
+type htmlConverter struct {
+	// Tags kept as html
+	kept map[string]bool
+	// Tags dropped with their content
+	dropped map[string]bool
+}
+
+var (
+	blankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+	spacesRegexp     = regexp.MustCompile(`\s+`)
+	langClassRegexp  = regexp.MustCompile(`(?:language-|lang-|brush:\s*)([\w+#-]+)`)
+	// Text at line start which would become list, quote or heading underline
+	lineStartRegexp = regexp.MustCompile(`^(?:(\d+)([.)])|([-+>=]))`)
+)
+
+// Escape text so that it is not parsed as markdown or html
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "&", "&amp;", "<", "&lt;",
+	"*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "#", `\#`, "`", "\\`",
+)
+
+// Convert html to markdown, return tags which are kept as html or dropped
+func HTMLToMarkdown(source string) (string, []string) {
+	nodes, err := html.ParseFragment(strings.NewReader(source), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return source, []string{"invalid html"}
+	}
+	conv := &htmlConverter{kept: make(map[string]bool), dropped: make(map[string]bool)}
+	root := &html.Node{Type: html.DocumentNode}
+	for _, node := range nodes {
+		root.AppendChild(node)
+	}
+	markdown := blankLinesRegexp.ReplaceAllString(conv.children(root), "\n\n")
+	notes := make([]string, 0)
+	for tag := range conv.kept {
+		notes = append(notes, "<"+tag+"> kept as html")
+	}
+	for tag := range conv.dropped {
+		notes = append(notes, "<"+tag+"> dropped")
+	}
+	sort.Strings(notes)
+	return strings.TrimSpace(markdown) + "\n", notes
+}
+
+func (conv *htmlConverter) children(node *html.Node) string {
+	var out strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		text := conv.convert(child)
+		// Spaces between blocks or after line break are not content
+		if child.Type == html.TextNode && (out.Len() == 0 || strings.HasSuffix(out.String(), "\n")) {
+			text = lineStartRegexp.ReplaceAllString(strings.TrimLeft(text, " "), `$1\$2$3`)
+		}
+		out.WriteString(text)
+	}
+	return out.String()
+}
+
+// Get text of node as it is, used by code blocks
+func nodeText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	var out strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == atom.Br {
+			out.WriteString("\n")
+			continue
+		}
+		out.WriteString(nodeText(child))
+	}
+	return out.String()
+}
+
+func nodeAttr(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// Keep element as html, block element is separated by blank lines
+func (conv *htmlConverter) keep(node *html.Node, block bool) string {
+	conv.kept[node.Data] = true
+	var out strings.Builder
+	html.Render(&out, node)
+	if !block {
+		return out.String()
+	}
+	return "\n\n" + out.String() + "\n\n"
+}
+
+// Wrap inline content with markdown delimiter, spaces stay outside
+func wrapInline(content string, delim string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return content
+	}
+	leading := content[:len(content)-len(strings.TrimLeft(content, " "))]
+	trailing := content[len(strings.TrimRight(content, " ")):]
+	return leading + delim + trimmed + delim + trailing
+}
+
+// Prefix lines of block, the first line may have a different prefix
+func prefixLines(block string, first string, rest string) string {
+	lines := strings.Split(strings.TrimSpace(block), "\n")
+	for i := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if lines[i] == "" {
+			lines[i] = strings.TrimRight(prefix, " ")
+		} else {
+			lines[i] = prefix + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (conv *htmlConverter) convert(node *html.Node) string {
+	switch node.Type {
+	case html.TextNode:
+		return markdownEscaper.Replace(spacesRegexp.ReplaceAllString(node.Data, " "))
+	case html.CommentNode:
+		// Keep <!--more--> of WordPress
+		if strings.TrimSpace(node.Data) == "more" {
+			return "\n\n" + MORE_SPLIT + "\n\n"
+		}
+		return ""
+	case html.ElementNode:
+	default:
+		return conv.children(node)
+	}
+	switch node.DataAtom {
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Main, atom.Figure:
+		return "\n\n" + strings.TrimSpace(conv.children(node)) + "\n\n"
+	case atom.Br:
+		return "  \n"
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level, _ := strconv.Atoi(node.Data[1:])
+		return "\n\n" + strings.Repeat("#", level) + " " + strings.TrimSpace(conv.children(node)) + "\n\n"
+	case atom.Strong, atom.B:
+		return wrapInline(conv.children(node), "**")
+	case atom.Em, atom.I:
+		return wrapInline(conv.children(node), "*")
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline(conv.children(node), "~~")
+	case atom.Code, atom.Kbd, atom.Tt:
+		code := nodeText(node)
+		if strings.Contains(code, "`") {
+			return "`` " + code + " ``"
+		}
+		return "`" + code + "`"
+	case atom.Pre:
+		lang := ""
+		for _, candidate := range []*html.Node{node, node.FirstChild} {
+			if candidate == nil || candidate.Type != html.ElementNode {
+				continue
+			}
+			if match := langClassRegexp.FindStringSubmatch(nodeAttr(candidate, "class")); match != nil {
+				lang = match[1]
+				break
+			}
+		}
+		code := strings.Trim(nodeText(node), "\n")
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		return "\n\n" + fence + lang + "\n" + code + "\n" + fence + "\n\n"
+	case atom.A:
+		content := conv.children(node)
+		href := nodeAttr(node, "href")
+		if href == "" || strings.TrimSpace(content) == "" {
+			return content
+		}
+		if title := nodeAttr(node, "title"); title != "" {
+			href += " \"" + strings.ReplaceAll(title, "\"", "\\\"") + "\""
+		}
+		return "[" + strings.TrimSpace(content) + "](" + href + ")"
+	case atom.Img:
+		src := nodeAttr(node, "src")
+		if title := nodeAttr(node, "title"); title != "" {
+			src += " \"" + strings.ReplaceAll(title, "\"", "\\\"") + "\""
+		}
+		return "![" + nodeAttr(node, "alt") + "](" + src + ")"
+	case atom.Figcaption:
+		return "\n\n" + wrapInline(strings.TrimSpace(conv.children(node)), "*") + "\n\n"
+	case atom.Ul, atom.Ol:
+		items := make([]string, 0)
+		index := 1
+		if start, err := strconv.Atoi(nodeAttr(node, "start")); err == nil {
+			index = start
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+				continue
+			}
+			marker := "- "
+			if node.DataAtom == atom.Ol {
+				marker = strconv.Itoa(index) + ". "
+				index++
+			}
+			content := blankLinesRegexp.ReplaceAllString(conv.children(child), "\n\n")
+			items = append(items, prefixLines(content, marker, strings.Repeat(" ", len(marker))))
+		}
+		return "\n\n" + strings.Join(items, "\n") + "\n\n"
+	case atom.Blockquote:
+		content := blankLinesRegexp.ReplaceAllString(conv.children(node), "\n\n")
+		return "\n\n" + prefixLines(content, "> ", "> ") + "\n\n"
+	case atom.Hr:
+		return "\n\n---\n\n"
+	case atom.Script, atom.Style, atom.Noscript, atom.Form, atom.Input, atom.Button:
+		conv.dropped[node.Data] = true
+		return ""
+	case atom.Table, atom.Iframe, atom.Video, atom.Audio, atom.Object, atom.Embed, atom.Dl:
+		return conv.keep(node, true)
+	case atom.Sup, atom.Sub:
+		return conv.keep(node, false)
+	}
+	return conv.children(node)
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"paragraphs", "<p>Hello</p><p>World</p>", "Hello\n\nWorld\n"},
+		{"inline", "<p><strong>bold</strong> and <em>em</em> <code>x*y</code></p>", "**bold** and *em* `x*y`\n"},
+		{"link", `<p><a href="/a" title="T">link</a></p>`, "[link](/a \"T\")\n"},
+		{"heading", "<h2>Title</h2>", "## Title\n"},
+		{"list", "<ol><li>one</li><li>two</li></ol>", "1. one\n2. two\n"},
+		{"pre", `<pre class="lang-go">a  := 1</pre>`, "```go\na  := 1\n```\n"},
+		{"escaped html", "<p>Use &lt;script&gt;alert(1)&lt;/script&gt; &amp; more</p>", "Use &lt;script>alert(1)&lt;/script> &amp; more\n"},
+		{"emphasis chars", "<p>a *b* _c_ [d](e) `f`</p>", "a \\*b\\* \\_c\\_ \\[d\\](e) \\`f\\`\n"},
+		{"heading text", "<p># not a heading</p>", "\\# not a heading\n"},
+		{"ordered text", "<p>1. not a list</p>", "1\\. not a list\n"},
+		{"bullet text", "<p>- not a list</p>", "\\- not a list\n"},
+		{"quote text", "<p>&gt; not a quote</p>", "\\> not a quote\n"},
+		{"backslash", `<p>C:\path</p>`, "C:\\\\path\n"},
+		{"more", "<p>a</p><!--more--><p>b</p>", "a\n\n<!--more-->\n\nb\n"},
+	}
+	for _, test := range tests {
+		got, _ := HTMLToMarkdown(test.html)
+		if got != test.want {
+			t.Errorf("%s: HTMLToMarkdown(%q) = %q, want %q", test.name, test.html, got, test.want)
+		}
+	}
+}
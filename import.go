@@ -0,0 +1,842 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/facebookgo/symwalk"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Article read from an export, written as markdown with front matter
+type ImportPost struct {
+	Title      string   `yaml:"title"`
+	Date       string   `yaml:"date,omitempty"`
+	Update     string   `yaml:"update,omitempty"`
+	Author     string   `yaml:"author"`
+	Cover      string   `yaml:"cover,omitempty"`
+	Draft      bool     `yaml:"draft,omitempty"`
+	Preview    string   `yaml:"preview,omitempty"`
+	Tags       []string `yaml:"tags,omitempty"`
+	Categories []string `yaml:"categories,omitempty"`
+	Type       string   `yaml:"type,omitempty"`
+	Slug       string   `yaml:"slug,omitempty"`
+	Url        string   `yaml:"url,omitempty"`
+	Aliases    []string `yaml:"aliases,omitempty"`
+	// Output path relative to source folder, such as post/hello.md
+	path    string
+	content string
+}
+
+// Items skipped or changed when importing
+type ImportReport struct {
+	Imported int
+	Skipped  []string
+	Warnings []string
+	// Authors used by imported posts
+	Authors map[string]bool
+}
+
+// Source of articles, such as a WordPress export
+type Importer interface {
+	Import(path string, report *ImportReport) []*ImportPost
+}
+
+var importers = map[string]Importer{
+	"wordpress": WordPressImporter{},
+	"hugo":      HugoImporter{},
+	"ghost":     GhostImporter{},
+	"jekyll":    MarkdownImporter{},
+	"hexo":      MarkdownImporter{},
+}
+
+// Date layouts found in exports and front matter
+var importDateLayouts = []string{
+	time.RFC3339,
+	DATE_FORMAT_WITH_TIMEZONE,
+	"2006-01-02 15:04:05 -07:00",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// Date layouts without timezone, the dates are local time of old blog
+var importLocalDateLayouts = []string{
+	"2006-01-02T15:04:05",
+	DATE_FORMAT,
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func NewImportReport() *ImportReport {
+	return &ImportReport{Authors: make(map[string]bool)}
+}
+
+func (report *ImportReport) Skip(item string, reason string) {
+	report.Skipped = append(report.Skipped, item+": "+reason)
+}
+
+func (report *ImportReport) Warn(item string, reason string) {
+	report.Warnings = append(report.Warnings, item+": "+reason)
+}
+
+// Print summary of import
+func (report *ImportReport) Print() {
+	fmt.Printf("\nImported %d articles, skipped %d items\n", report.Imported, len(report.Skipped))
+	if len(report.Skipped) > 0 {
+		Warn("Skipped:")
+		for _, item := range report.Skipped {
+			fmt.Println("  " + item)
+		}
+	}
+	if len(report.Warnings) > 0 {
+		Warn("Check these articles:")
+		for _, item := range report.Warnings {
+			fmt.Println("  " + item)
+		}
+	}
+	if len(report.Authors) > 0 {
+		authors := make([]string, 0, len(report.Authors))
+		for author := range report.Authors {
+			authors = append(authors, author)
+		}
+		sort.Strings(authors)
+		Log("Authors to define in config.yml: " + strings.Join(authors, ", "))
+	}
+}
+
+// Parse date of export, output it in the format of ink
+func formatImportDate(value interface{}) (string, error) {
+	switch date := value.(type) {
+	case nil:
+		return "", nil
+	case time.Time:
+		return date.Format(DATE_FORMAT_WITH_TIMEZONE), nil
+	case string:
+		date = strings.TrimSpace(date)
+		if date == "" {
+			return "", nil
+		}
+		for _, layout := range importDateLayouts {
+			if parsed, err := time.Parse(layout, date); err == nil {
+				return parsed.Format(DATE_FORMAT_WITH_TIMEZONE), nil
+			}
+		}
+		for _, layout := range importLocalDateLayouts {
+			if parsed, err := time.Parse(layout, date); err == nil {
+				return parsed.Format(DATE_FORMAT), nil
+			}
+		}
+		return "", errors.New("unknown date format: " + date)
+	}
+	return "", fmt.Errorf("unknown date: %v", value)
+}
+
+// Split front matter and content, format is yaml, toml or json
+func splitFrontMatter(data []byte) (string, string, string) {
+	text := strings.TrimLeft(strings.ReplaceAll(string(data), "\r\n", "\n"), "\ufeff\n ")
+	if strings.HasPrefix(text, "{") {
+		decoder := json.NewDecoder(strings.NewReader(text))
+		var meta json.RawMessage
+		if err := decoder.Decode(&meta); err == nil {
+			return "json", string(meta), text[decoder.InputOffset():]
+		}
+	}
+	// Delimiter is a whole line, not any occurrence in the text
+	lines := strings.SplitAfter(text, "\n")
+	for _, delim := range []string{"---", "+++"} {
+		if strings.TrimSpace(lines[0]) != delim {
+			continue
+		}
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == delim {
+				format := "yaml"
+				if delim == "+++" {
+					format = "toml"
+				}
+				return format, strings.Join(lines[1:i], ""), strings.Join(lines[i+1:], "")
+			}
+		}
+	}
+	// Front matter of Hexo and ink has no opening delimiter
+	for i, line := range lines {
+		if strings.TrimSpace(line) == CONFIG_SPLIT {
+			return "yaml", strings.Join(lines[:i], ""), strings.Join(lines[i+1:], "")
+		}
+	}
+	return "", "", text
+}
+
+// Parse front matter into map
+func parseFrontMatter(format string, front string) (map[string]interface{}, error) {
+	meta := make(map[string]interface{})
+	var err error
+	switch format {
+	case "json":
+		err = json.Unmarshal([]byte(front), &meta)
+	case "toml":
+		err = toml.Unmarshal([]byte(front), &meta)
+	case "yaml":
+		err = yaml.Unmarshal([]byte(front), &meta)
+		// Dates are kept as written, timezone of old blog is unknown
+		var nodes map[string]yaml.Node
+		if yaml.Unmarshal([]byte(front), &nodes) == nil {
+			for key, node := range nodes {
+				if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!timestamp" {
+					meta[key] = node.Value
+				}
+			}
+		}
+	}
+	return meta, err
+}
+
+func metaString(meta map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := meta[key].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case nil:
+		default:
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
+
+func metaStrings(meta map[string]interface{}, keys ...string) []string {
+	values := make([]string, 0)
+	for _, key := range keys {
+		switch value := meta[key].(type) {
+		case string:
+			if value != "" {
+				values = append(values, value)
+			}
+		case []interface{}:
+			for _, item := range value {
+				values = append(values, fmt.Sprint(item))
+			}
+		}
+	}
+	return values
+}
+
+func metaBool(meta map[string]interface{}, key string) bool {
+	value, _ := meta[key].(bool)
+	return value
+}
+
+// Create post from front matter of Jekyll, Hexo or Hugo
+func postFromMeta(meta map[string]interface{}, item string, report *ImportReport) *ImportPost {
+	post := &ImportPost{
+		Title:      metaString(meta, "title"),
+		Author:     metaString(meta, "author"),
+		Cover:      metaString(meta, "cover", "image", "featured_image", "thumbnail"),
+		Draft:      metaBool(meta, "draft") || metaString(meta, "published") == "false",
+		Preview:    metaString(meta, "preview", "summary", "description", "excerpt"),
+		Tags:       metaStrings(meta, "tags"),
+		Categories: metaStrings(meta, "categories", "category"),
+		Type:       metaString(meta, "type"),
+		Slug:       metaString(meta, "slug"),
+		Url:        strings.TrimPrefix(metaString(meta, "url", "permalink"), "/"),
+		Aliases:    metaStrings(meta, "aliases"),
+	}
+	if post.Author == "" {
+		if authors := metaStrings(meta, "authors"); len(authors) > 0 {
+			post.Author = authors[0]
+		}
+	}
+	var err error
+	if post.Date, err = formatImportDate(meta["date"]); err != nil {
+		report.Warn(item, err.Error())
+	}
+	for _, key := range []string{"lastmod", "updated", "update"} {
+		if meta[key] != nil {
+			if post.Update, err = formatImportDate(meta[key]); err != nil {
+				report.Warn(item, err.Error())
+			}
+			break
+		}
+	}
+	return post
+}
+
+// Write posts into folder, existing files are not overwritten
+func writeImportPosts(posts []*ImportPost, dir string, report *ImportReport) {
+	used := make(map[string]bool)
+	for _, post := range posts {
+		if post.Author == "" {
+			post.Author = "me"
+		}
+		report.Authors[post.Author] = true
+		if post.Type == "post" {
+			post.Type = ""
+		}
+		// Posts with the same name get a number suffix
+		ext := filepath.Ext(post.path)
+		base := strings.TrimSuffix(post.path, ext)
+		for i := 2; used[post.path]; i++ {
+			post.path = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		used[post.path] = true
+		target := filepath.Join(dir, filepath.FromSlash(post.path))
+		if Exists(target) {
+			report.Skip(post.path, "file already exists")
+			continue
+		}
+		var out bytes.Buffer
+		encoder := yaml.NewEncoder(&out)
+		encoder.SetIndent(4)
+		if err := encoder.Encode(post); err != nil {
+			report.Skip(post.path, err.Error())
+			continue
+		}
+		out.WriteString("\n" + CONFIG_SPLIT + "\n\n" + strings.TrimSpace(post.content) + "\n")
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			Fatal(err.Error())
+		}
+		if err := os.WriteFile(target, out.Bytes(), 0644); err != nil {
+			Fatal(err.Error())
+		}
+		Log("Imported " + post.path)
+		report.Imported++
+	}
+}
+
+// Markdown files of Jekyll and Hexo
+type MarkdownImporter struct{}
+
+func (MarkdownImporter) Import(sourcePath string, report *ImportReport) []*ImportPost {
+	posts := make([]*ImportPost, 0)
+	symwalk.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() {
+			return nil
+		}
+		relPath, _ := filepath.Rel(sourcePath, path)
+		fileExt := strings.ToLower(filepath.Ext(path))
+		if fileExt != ".md" && fileExt != ".markdown" && fileExt != ".html" {
+			report.Skip(relPath, "not an article")
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			report.Skip(relPath, err.Error())
+			return nil
+		}
+		format, front, content := splitFrontMatter(data)
+		if format == "" {
+			report.Skip(relPath, "no front matter")
+			return nil
+		}
+		meta, err := parseFrontMatter(format, front)
+		if err != nil {
+			report.Skip(relPath, "invalid front matter: "+err.Error())
+			return nil
+		}
+		post := postFromMeta(meta, relPath, report)
+		post.content = content
+		post.path = strings.TrimSuffix(filepath.ToSlash(relPath), filepath.Ext(relPath)) + ".md"
+		posts = append(posts, post)
+		return nil
+	})
+	return posts
+}
+
+// Import articles of other blog systems into source folder
+func Import(c *cli.Context) {
+	if c.Args().Len() < 2 {
+		Fatal("Usage: ink import <wordpress|hugo|ghost|jekyll|hexo> <path>")
+	}
+	CheckArgs(c, 2)
+	kind := strings.ToLower(c.Args().Get(0))
+	importer, ok := importers[kind]
+	if !ok {
+		Fatal("Unknown import source: " + kind)
+	}
+	exportPath := c.Args().Get(1)
+	if !Exists(exportPath) {
+		Fatal("Export not found: " + exportPath)
+	}
+	root := c.String("root")
+	if root == "" {
+		root = FindRootPath()
+	}
+	if !Exists(filepath.Join(root, "config.yml")) {
+		Fatal("config.yml not found in " + root + ", please specify a blog with --root")
+	}
+	report := NewImportReport()
+	posts := importer.Import(exportPath, report)
+	writeImportPosts(posts, filepath.Join(root, "source"), report)
+	report.Print()
+}
+
+// Convert Jekyll and Hexo posts in folder, output keeps sub folders of source
+func Convert(c *cli.Context) {
+	args := c.Args()
+	if args.Len() == 0 {
+		Fatal("Please specify the posts source path")
+	}
+	sourcePath := args.Get(0)
+	targetPath := "."
+	if args.Len() > 1 {
+		targetPath = args.Get(1)
+	}
+	if !Exists(sourcePath) || !Exists(targetPath) {
+		Fatal("Please specify valid path")
+	}
+	report := NewImportReport()
+	posts := MarkdownImporter{}.Import(sourcePath, report)
+	writeImportPosts(posts, targetPath, report)
+	report.Print()
+}
+
+// Item of WordPress WXR export
+type wxrItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	Creator string `xml:"creator"`
+	// content:encoded and excerpt:encoded
+	Encoded []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:"encoded"`
+	PostId     string `xml:"post_id"`
+	PostName   string `xml:"post_name"`
+	PostDate   string `xml:"post_date"`
+	Status     string `xml:"status"`
+	PostType   string `xml:"post_type"`
+	Attachment string `xml:"attachment_url"`
+	Categories []struct {
+		Domain string `xml:"domain,attr"`
+		Value  string `xml:",chardata"`
+	} `xml:"category"`
+	Meta []struct {
+		Key   string `xml:"meta_key"`
+		Value string `xml:"meta_value"`
+	} `xml:"postmeta"`
+}
+
+type wxrExport struct {
+	Items []wxrItem `xml:"channel>item"`
+}
+
+var (
+	// Shortcodes of WordPress are not converted
+	wpShortcodeRegexp = regexp.MustCompile(`\[(?:caption|gallery|embed|audio|video|playlist|sourcecode|code)\b|\[/\w+\]`)
+	wpBlockRegexp     = regexp.MustCompile(`^<(?:p|div|pre|ul|ol|li|h[1-6]|blockquote|table|figure|hr|!--)[\s>/]`)
+	blankLineRegexp   = regexp.MustCompile(`\n[ \t]*\n`)
+)
+
+// WordPress WXR export, it is exported by Tools > Export in dashboard
+type WordPressImporter struct{}
+
+// Add paragraphs to content of classic editor, which uses blank lines instead
+func wpAutoParagraph(content string) string {
+	if strings.Contains(content, "<!-- wp:") {
+		return content
+	}
+	chunks := blankLineRegexp.Split(strings.ReplaceAll(content, "\r\n", "\n"), -1)
+	inPre := false
+	for i, chunk := range chunks {
+		opened := strings.Count(chunk, "<pre") > strings.Count(chunk, "</pre>")
+		closed := strings.Count(chunk, "</pre>") > strings.Count(chunk, "<pre")
+		// Spaces of preformatted text are kept
+		if trimmed := strings.TrimSpace(chunk); !inPre && trimmed != "" && !wpBlockRegexp.MatchString(trimmed) {
+			chunk = "<p>" + strings.ReplaceAll(trimmed, "\n", "<br>\n") + "</p>"
+		}
+		if opened {
+			inPre = true
+		} else if closed {
+			inPre = false
+		}
+		chunks[i] = chunk
+	}
+	return strings.Join(chunks, "\n\n")
+}
+
+func (WordPressImporter) Import(exportPath string, report *ImportReport) []*ImportPost {
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		Fatal(err.Error())
+	}
+	var export wxrExport
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	if err := decoder.Decode(&export); err != nil {
+		Fatal("Invalid WordPress export: " + err.Error())
+	}
+	// Attachment urls by id, used by featured images
+	attachments := make(map[string]string)
+	for _, item := range export.Items {
+		if item.PostType == "attachment" {
+			attachments[item.PostId] = item.Attachment
+		}
+	}
+	if len(attachments) > 0 {
+		report.Skip(fmt.Sprintf("%d attachments", len(attachments)), "media files are not downloaded, links still point to the old site")
+	}
+	posts := make([]*ImportPost, 0)
+	for _, item := range export.Items {
+		name := item.Title
+		if name == "" {
+			name = "#" + item.PostId
+		}
+		switch item.PostType {
+		case "post", "page":
+		case "attachment":
+			continue
+		default:
+			report.Skip(name, "unsupported type "+item.PostType)
+			continue
+		}
+		if item.Status == "trash" || item.Status == "auto-draft" || item.Status == "inherit" {
+			report.Skip(name, "status is "+item.Status)
+			continue
+		}
+		post := &ImportPost{
+			Title:  item.Title,
+			Author: item.Creator,
+			Draft:  item.Status != "publish",
+		}
+		if item.PostDate != "0000-00-00 00:00:00" {
+			if post.Date, err = formatImportDate(item.PostDate); err != nil {
+				report.Warn(name, err.Error())
+			}
+		}
+		content := ""
+		for _, encoded := range item.Encoded {
+			if strings.Contains(encoded.XMLName.Space, "excerpt") {
+				post.Preview = strings.TrimSpace(encoded.Value)
+			} else {
+				content = encoded.Value
+			}
+		}
+		for _, category := range item.Categories {
+			switch category.Domain {
+			case "category":
+				if category.Value != "Uncategorized" {
+					post.Categories = append(post.Categories, category.Value)
+				}
+			case "post_tag":
+				post.Tags = append(post.Tags, category.Value)
+			}
+		}
+		for _, meta := range item.Meta {
+			if meta.Key == "_thumbnail_id" {
+				post.Cover = attachments[meta.Value]
+			}
+		}
+		// Post name is url encoded for non ascii slugs
+		slug, err := url.PathUnescape(item.PostName)
+		if err != nil || slug == "" {
+			slug = item.PostName
+		}
+		if slug == "" {
+			slug = Slugify(item.Title)
+		}
+		if slug == "" {
+			slug = item.PostId
+		}
+		post.Slug = slug
+		// Old permalinks are kept as aliases
+		if link, err := url.Parse(item.Link); err == nil && link.Path != "" && link.RawQuery == "" && post.Date != "" {
+			post.Aliases = []string{link.Path}
+		}
+		post.path = "post/" + slug + ".md"
+		if item.PostType == "page" {
+			post.Type = "page"
+			post.path = "page/" + slug + ".md"
+		}
+		if wpShortcodeRegexp.MatchString(content) {
+			report.Warn(post.path, "contains WordPress shortcodes")
+		}
+		var notes []string
+		post.content, notes = HTMLToMarkdown(wpAutoParagraph(content))
+		for _, note := range notes {
+			report.Warn(post.path, note)
+		}
+		posts = append(posts, post)
+	}
+	return posts
+}
+
+// Content folder of Hugo site
+type HugoImporter struct{}
+
+var hugoShortcodeRegexp = regexp.MustCompile(`\{\{[<%]`)
+
+func (HugoImporter) Import(sitePath string, report *ImportReport) []*ImportPost {
+	contentPath := filepath.Join(sitePath, "content")
+	if !Exists(contentPath) {
+		// Content folder itself is given
+		contentPath = sitePath
+	}
+	posts := make([]*ImportPost, 0)
+	symwalk.Walk(contentPath, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() {
+			return nil
+		}
+		relPath, _ := filepath.Rel(contentPath, path)
+		relPath = filepath.ToSlash(relPath)
+		fileName := filepath.Base(path)
+		fileExt := strings.ToLower(filepath.Ext(path))
+		if fileExt != ".md" && fileExt != ".markdown" {
+			// Resources of page bundles
+			if Exists(filepath.Join(filepath.Dir(path), "index.md")) {
+				report.Skip(relPath, "bundle resource is not copied")
+			} else {
+				report.Skip(relPath, "not an article")
+			}
+			return nil
+		}
+		if strings.HasPrefix(fileName, "_index.") {
+			report.Skip(relPath, "section page")
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			report.Skip(relPath, err.Error())
+			return nil
+		}
+		format, front, content := splitFrontMatter(data)
+		meta, err := parseFrontMatter(format, front)
+		if err != nil {
+			report.Skip(relPath, "invalid front matter: "+err.Error())
+			return nil
+		}
+		post := postFromMeta(meta, relPath, report)
+		post.content = content
+		// Page bundle is named by its folder
+		name := strings.TrimSuffix(relPath, filepath.Ext(relPath))
+		if strings.HasSuffix(name, "/index") {
+			name = strings.TrimSuffix(name, "/index")
+		}
+		dir, base := "", name
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			dir, base = name[:i], name[i+1:]
+		}
+		if dir == "" && post.Type == "" {
+			// Files at the top of content are pages
+			post.Type = "page"
+		}
+		if post.Type == "page" {
+			post.path = "page/" + base + ".md"
+		} else {
+			for _, section := range []string{"posts", "post", "blog"} {
+				if dir == section {
+					dir = ""
+				} else {
+					dir = strings.TrimPrefix(dir, section+"/")
+				}
+			}
+			post.path = strings.TrimPrefix(dir+"/"+base+".md", "/")
+			post.path = "post/" + post.path
+		}
+		// Default link of Hugo is kept as alias when url is not customized
+		if post.Url == "" {
+			slug := post.Slug
+			if slug == "" {
+				slug = base
+			}
+			hugoLink := "/" + strings.TrimPrefix(strings.TrimSuffix(name, base)+slug, "/") + "/"
+			post.Aliases = append([]string{hugoLink}, post.Aliases...)
+		}
+		if hugoShortcodeRegexp.MatchString(content) {
+			report.Warn(post.path, "contains Hugo shortcodes")
+		}
+		if format == "" {
+			report.Warn(post.path, "no front matter")
+		}
+		posts = append(posts, post)
+		return nil
+	})
+	return posts
+}
+
+// JSON export of Ghost, it is exported by Settings > Labs in admin
+type GhostImporter struct{}
+
+type ghostData struct {
+	Posts []struct {
+		Id              string  `json:"id"`
+		Title           string  `json:"title"`
+		Slug            string  `json:"slug"`
+		Html            *string `json:"html"`
+		Mobiledoc       *string `json:"mobiledoc"`
+		Lexical         *string `json:"lexical"`
+		FeatureImage    *string `json:"feature_image"`
+		CustomExcerpt   *string `json:"custom_excerpt"`
+		Status          string  `json:"status"`
+		Type            string  `json:"type"`
+		Page            bool    `json:"page"`
+		PublishedAt     *string `json:"published_at"`
+		UpdatedAt       *string `json:"updated_at"`
+		CreatedAt       *string `json:"created_at"`
+		AuthorId        string  `json:"author_id"`
+		CanonicalUrl    *string `json:"canonical_url"`
+		MetaDescription *string `json:"meta_description"`
+	} `json:"posts"`
+	Tags []struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"tags"`
+	PostsTags []struct {
+		PostId string `json:"post_id"`
+		TagId  string `json:"tag_id"`
+	} `json:"posts_tags"`
+	Users []struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"users"`
+	PostsAuthors []struct {
+		PostId   string `json:"post_id"`
+		AuthorId string `json:"author_id"`
+	} `json:"posts_authors"`
+}
+
+// Markdown card of mobiledoc, used by posts of old Ghost versions
+func ghostMobiledocMarkdown(mobiledoc string) string {
+	var doc struct {
+		Cards [][]json.RawMessage `json:"cards"`
+	}
+	if json.Unmarshal([]byte(mobiledoc), &doc) != nil {
+		return ""
+	}
+	for _, card := range doc.Cards {
+		if len(card) < 2 {
+			continue
+		}
+		var name string
+		var payload struct {
+			Markdown string `json:"markdown"`
+		}
+		json.Unmarshal(card[0], &name)
+		json.Unmarshal(card[1], &payload)
+		if (name == "markdown" || name == "card-markdown") && payload.Markdown != "" {
+			return payload.Markdown
+		}
+	}
+	return ""
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func (GhostImporter) Import(exportPath string, report *ImportReport) []*ImportPost {
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		Fatal(err.Error())
+	}
+	var export struct {
+		Db []struct {
+			Data ghostData `json:"data"`
+		} `json:"db"`
+		Data *ghostData `json:"data"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		Fatal("Invalid Ghost export: " + err.Error())
+	}
+	var ghost ghostData
+	if len(export.Db) > 0 {
+		ghost = export.Db[0].Data
+	} else if export.Data != nil {
+		ghost = *export.Data
+	} else {
+		Fatal("Invalid Ghost export: no data found")
+	}
+	tags := make(map[string]string)
+	for _, tag := range ghost.Tags {
+		// Internal tags start with #
+		if !strings.HasPrefix(tag.Name, "#") {
+			tags[tag.Id] = tag.Name
+		}
+	}
+	postTags := make(map[string][]string)
+	for _, postTag := range ghost.PostsTags {
+		if name, ok := tags[postTag.TagId]; ok {
+			postTags[postTag.PostId] = append(postTags[postTag.PostId], name)
+		}
+	}
+	users := make(map[string]string)
+	for _, user := range ghost.Users {
+		users[user.Id] = user.Slug
+	}
+	postAuthors := make(map[string]string)
+	for _, postAuthor := range ghost.PostsAuthors {
+		// The first author is the primary one
+		if _, ok := postAuthors[postAuthor.PostId]; !ok {
+			postAuthors[postAuthor.PostId] = users[postAuthor.AuthorId]
+		}
+	}
+	posts := make([]*ImportPost, 0)
+	for _, ghostPost := range ghost.Posts {
+		slug := ghostPost.Slug
+		if slug == "" {
+			slug = ghostPost.Id
+		}
+		post := &ImportPost{
+			Title:   ghostPost.Title,
+			Author:  postAuthors[ghostPost.Id],
+			Cover:   strings.ReplaceAll(stringValue(ghostPost.FeatureImage), "__GHOST_URL__", ""),
+			Draft:   ghostPost.Status == "draft" || ghostPost.Status == "scheduled",
+			Preview: stringValue(ghostPost.CustomExcerpt),
+			Tags:    postTags[ghostPost.Id],
+			Slug:    slug,
+			path:    "post/" + slug + ".md",
+			// Ghost links are /slug/ by default
+			Aliases: []string{"/" + slug + "/"},
+		}
+		if post.Author == "" {
+			post.Author = users[ghostPost.AuthorId]
+		}
+		if ghostPost.Type == "page" || ghostPost.Page {
+			post.Type = "page"
+			post.path = "page/" + slug + ".md"
+		}
+		for _, date := range []*string{ghostPost.PublishedAt, ghostPost.CreatedAt} {
+			if stringValue(date) != "" {
+				if post.Date, err = formatImportDate(*date); err != nil {
+					report.Warn(post.path, err.Error())
+				}
+				break
+			}
+		}
+		if post.Update, err = formatImportDate(stringValue(ghostPost.UpdatedAt)); err != nil {
+			report.Warn(post.path, err.Error())
+		}
+		content := strings.ReplaceAll(stringValue(ghostPost.Html), "__GHOST_URL__", "")
+		if content != "" {
+			var notes []string
+			post.content, notes = HTMLToMarkdown(content)
+			for _, note := range notes {
+				report.Warn(post.path, note)
+			}
+		} else if markdown := ghostMobiledocMarkdown(stringValue(ghostPost.Mobiledoc)); markdown != "" {
+			post.content = strings.ReplaceAll(markdown, "__GHOST_URL__", "")
+		} else if stringValue(ghostPost.Lexical) != "" {
+			report.Skip(post.path, "no html in export, export it again from Ghost 5 or later")
+			continue
+		}
+		posts = append(posts, post)
+	}
+	return posts
+}
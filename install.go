@@ -0,0 +1,433 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/facebookgo/symwalk"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Installed theme recorded in lock file
+type ThemeLock struct {
+	Source string
+	// git or zip
+	Type string
+	// Branch, tag or commit requested for git themes
+	Ref string `yaml:",omitempty"`
+	// Resolved commit of git themes or sha256 of zip themes
+	Version   string
+	Installed string
+}
+
+type ThemeLockFile struct {
+	Themes map[string]ThemeLock
+}
+
+const (
+	THEMES_DIR       = "themes"
+	THEME_LOCK_FILE  = "theme.lock"
+	THEME_TYPE_GIT   = "git"
+	THEME_TYPE_ZIP   = "zip"
+	DOWNLOAD_TIMEOUT = 60 * time.Second
+)
+
+// Templates a theme must provide unless it extends another theme
+var requiredTemplates = []string{"article.html", "archive.html", "tag.html"}
+
+func themeLockPath(root string) string {
+	return filepath.Join(root, THEME_LOCK_FILE)
+}
+
+func readThemeLock(root string) *ThemeLockFile {
+	lock := &ThemeLockFile{Themes: make(map[string]ThemeLock)}
+	data, err := os.ReadFile(themeLockPath(root))
+	if os.IsNotExist(err) {
+		return lock
+	}
+	if err != nil {
+		Fatal(err.Error())
+	}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		Fatal(NewYamlError(themeLockPath(root), 0, err).Error())
+	}
+	if lock.Themes == nil {
+		lock.Themes = make(map[string]ThemeLock)
+	}
+	return lock
+}
+
+func writeThemeLock(root string, lock *ThemeLockFile) {
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		Fatal(err.Error())
+	}
+	header := "# Generated by 'ink theme', do not edit\n"
+	if err := os.WriteFile(themeLockPath(root), append([]byte(header), data...), 0644); err != nil {
+		Fatal(err.Error())
+	}
+}
+
+// Get theme type by source
+func themeSourceType(source string) string {
+	if strings.HasSuffix(strings.ToLower(source), ".zip") {
+		return THEME_TYPE_ZIP
+	}
+	return THEME_TYPE_GIT
+}
+
+// Get theme name from source, such as ink-theme-dark of .../ink-theme-dark.git
+func themeSourceName(source string) string {
+	name := strings.TrimRight(filepath.ToSlash(source), "/")
+	name = name[strings.LastIndexAny(name, "/:")+1:]
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return name
+}
+
+// Run git command and get trimmed output
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.New("git " + args[0] + ": " + strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Clone git theme to dest without .git folder, return resolved commit
+func fetchGitTheme(source string, ref string, dest string) (string, error) {
+	cloneDir, err := os.MkdirTemp("", "ink-theme-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(cloneDir)
+	if _, err := runGit("", "clone", "--quiet", source, cloneDir); err != nil {
+		return "", err
+	}
+	if ref != "" {
+		if _, err := runGit(cloneDir, "checkout", "--quiet", ref); err != nil {
+			return "", err
+		}
+	}
+	commit, err := runGit(cloneDir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	os.RemoveAll(filepath.Join(cloneDir, ".git"))
+	return commit, copyTree(cloneDir, dest)
+}
+
+// Read zip from local path or url
+func readZipSource(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+	client := http.Client{Timeout: DOWNLOAD_TIMEOUT}
+	res, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download %s: %s", source, res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// Extract zip theme to dest, return sha256 of archive
+func fetchZipTheme(source string, dest string) (string, error) {
+	data, err := readZipSource(source)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	// Strip the top folder shared by all files, such as repo-master/ of GitHub archives
+	prefix := ""
+	for i, file := range reader.File {
+		top := strings.SplitN(file.Name, "/", 2)[0] + "/"
+		if i == 0 {
+			prefix = top
+		}
+		if !strings.HasPrefix(file.Name, prefix) || file.Name == strings.TrimSuffix(prefix, "/") {
+			prefix = ""
+			break
+		}
+	}
+	for _, file := range reader.File {
+		relPath := strings.TrimPrefix(file.Name, prefix)
+		if relPath == "" {
+			continue
+		}
+		target := filepath.Join(dest, filepath.FromSlash(relPath))
+		if !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return "", errors.New("illegal file path in zip: " + file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return "", err
+		}
+		if err := extractZipFile(file, target); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func extractZipFile(file *zip.File, target string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dest, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	_, err = io.Copy(dest, src)
+	return err
+}
+
+// Copy files of folder to dest
+func copyTree(src string, dest string) error {
+	return symwalk.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(src, path)
+		target := filepath.Join(dest, relPath)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// Check theme config and templates
+func ValidateTheme(themeDir string) []error {
+	errs := make([]error, 0)
+	configPath := filepath.Join(themeDir, "config.yml")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return append(errs, errors.New("missing config.yml"))
+	}
+	// Unknown keys are warned as when building
+	issues := CheckYaml(configPath, 0, data, ThemeConfig{})
+	for _, warning := range issues.Warnings {
+		Warn(warning.Error())
+	}
+	for _, issue := range issues.Errors {
+		errs = append(errs, issue)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	var config ThemeConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return append(errs, NewYamlError(configPath, 0, err))
+	}
+	for _, copyItem := range config.Copy {
+		if copyItem == "" || filepath.IsAbs(copyItem) || strings.HasPrefix(filepath.Clean(copyItem), "..") {
+			errs = append(errs, errors.New("copy item must be a relative path in theme: "+copyItem))
+		} else if config.Extends == "" && !Exists(filepath.Join(themeDir, copyItem)) {
+			errs = append(errs, errors.New("copy item not found: "+copyItem))
+		}
+	}
+	for item, langItem := range config.Lang {
+		if len(langItem) == 0 {
+			errs = append(errs, errors.New("lang item has no translation: "+item))
+		}
+	}
+	// Templates may be provided by the parent theme
+	if config.Extends == "" {
+		for _, name := range requiredTemplates {
+			if !Exists(filepath.Join(themeDir, name)) {
+				errs = append(errs, errors.New("missing template: "+name))
+			}
+		}
+		if !Exists(filepath.Join(themeDir, "list.html")) && !Exists(filepath.Join(themeDir, "page.html")) {
+			errs = append(errs, errors.New("missing template: list.html"))
+		}
+	}
+	return errs
+}
+
+// Fetch and validate theme, then replace the installed one
+func installTheme(root string, name string, lock ThemeLock) ThemeLock {
+	themesDir := filepath.Join(root, THEMES_DIR)
+	if err := os.MkdirAll(themesDir, 0755); err != nil {
+		Fatal(err.Error())
+	}
+	tmpDir, err := os.MkdirTemp(themesDir, "."+name+"-")
+	if err != nil {
+		Fatal(err.Error())
+	}
+	defer os.RemoveAll(tmpDir)
+	if lock.Type == THEME_TYPE_ZIP {
+		lock.Version, err = fetchZipTheme(lock.Source, tmpDir)
+	} else {
+		lock.Version, err = fetchGitTheme(lock.Source, lock.Ref, tmpDir)
+	}
+	if err != nil {
+		Fatal(err.Error())
+	}
+	if errs := ValidateTheme(tmpDir); len(errs) > 0 {
+		for _, err := range errs {
+			Error(strings.Replace(err.Error(), tmpDir, filepath.Join(themesDir, name), 1))
+		}
+		Fatal("Invalid theme: " + lock.Source)
+	}
+	themeDir := filepath.Join(themesDir, name)
+	if err := os.RemoveAll(themeDir); err != nil {
+		Fatal(err.Error())
+	}
+	if err := os.Rename(tmpDir, themeDir); err != nil {
+		Fatal(err.Error())
+	}
+	lock.Installed = time.Now().Format(DATE_FORMAT)
+	return lock
+}
+
+func themeRootPath(c *cli.Context) string {
+	if root := c.String("root"); root != "" {
+		return root
+	}
+	return FindRootPath()
+}
+
+// Install theme from git repo or zip
+func ThemeAdd(c *cli.Context) {
+	if c.Args().Len() < 1 {
+		Fatal("Please specify the git url or zip file of theme")
+	}
+	CheckArgs(c, 1)
+	root := themeRootPath(c)
+	source := c.Args().First()
+	// Keep local sources usable from other folders
+	if Exists(source) {
+		source, _ = filepath.Abs(source)
+	}
+	name := c.String("name")
+	if name == "" {
+		name = themeSourceName(source)
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		Fatal("Invalid theme name: " + name)
+	}
+	lockFile := readThemeLock(root)
+	if _, ok := lockFile.Themes[name]; ok {
+		Fatal("Theme " + name + " is already installed, use 'ink theme update " + name + "'")
+	}
+	lock := ThemeLock{Source: source, Type: themeSourceType(source), Ref: c.String("ref")}
+	if lock.Type == THEME_TYPE_ZIP && lock.Ref != "" {
+		Fatal("--ref is only supported by git themes")
+	}
+	lockFile.Themes[name] = installTheme(root, name, lock)
+	writeThemeLock(root, lockFile)
+	Log("Installed theme " + name + " " + lockFile.Themes[name].Version)
+	Log("Set 'theme: " + filepath.ToSlash(filepath.Join(THEMES_DIR, name)) + "' in config.yml to use it")
+}
+
+// Print installed themes
+func ThemeList(c *cli.Context) {
+	root := themeRootPath(c)
+	lockFile := readThemeLock(root)
+	current := ""
+	if data, err := os.ReadFile(filepath.Join(root, "config.yml")); err == nil {
+		var config GlobalConfig
+		if yaml.Unmarshal(data, &config) == nil {
+			current = filepath.Clean(config.Site.Theme)
+		}
+	}
+	names := make([]string, 0, len(lockFile.Themes))
+	for name := range lockFile.Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		Log("No theme installed")
+	}
+	for _, name := range names {
+		lock := lockFile.Themes[name]
+		mark := " "
+		if current == filepath.Join(THEMES_DIR, name) {
+			mark = "*"
+		}
+		version := lock.Version
+		if len(version) > 12 {
+			version = version[:12]
+		}
+		if lock.Ref != "" {
+			version = lock.Ref + "@" + version
+		}
+		status := ""
+		if !Exists(filepath.Join(root, THEMES_DIR, name)) {
+			status = " (missing)"
+		}
+		fmt.Printf("%s %-20s %-4s %s %s%s\n", mark, name, lock.Type, version, lock.Source, status)
+	}
+}
+
+// Fetch themes again and reinstall changed ones
+func ThemeUpdate(c *cli.Context) {
+	root := themeRootPath(c)
+	lockFile := readThemeLock(root)
+	names := c.Args().Slice()
+	if len(names) == 0 {
+		for name := range lockFile.Themes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		lock, ok := lockFile.Themes[name]
+		if !ok {
+			Fatal("Theme is not installed: " + name)
+		}
+		if c.IsSet("ref") {
+			if lock.Type != THEME_TYPE_GIT {
+				Fatal("--ref is only supported by git themes")
+			}
+			lock.Ref = c.String("ref")
+		}
+		oldVersion := lock.Version
+		updated := installTheme(root, name, lock)
+		if updated.Version == oldVersion {
+			updated.Installed = lock.Installed
+			Log("Theme " + name + " is up to date")
+		} else {
+			Log("Updated theme " + name + " " + oldVersion + " -> " + updated.Version)
+		}
+		lockFile.Themes[name] = updated
+	}
+	writeThemeLock(root, lockFile)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/InkProject/ink.go"
+	"github.com/gorilla/websocket"
+)
+
+// Message sent to live reload clients
+type LiveMessage struct {
+	Type  string      `json:"type"`
+	Paths []string    `json:"paths,omitempty"`
+	Error *BuildError `json:"error,omitempty"`
+}
+
+// Connected live reload client
+type liveClient struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// Broadcast messages to all connected clients
+type LiveHub struct {
+	sync.Mutex
+	clients map[*liveClient]bool
+	// Error of last build, sent to new clients
+	lastError []byte
+}
+
+var liveHub = &LiveHub{clients: make(map[*liveClient]bool)}
+
+// Content hash of outputs in last build
+var outputHashes map[string]uint64
+
+const LIVE_RELOAD_SCRIPT = `<script type="text/javascript">
+(function() {
+    var root = '{{ROOT}}';
+    var connectTimer;
+    var escapeHTML = function(str) {
+        var div = document.createElement('div');
+        div.textContent = str;
+        return div.innerHTML;
+    };
+    var hideError = function() {
+        var overlay = document.getElementById('ink-error-overlay');
+        if (overlay) overlay.parentNode.removeChild(overlay);
+    };
+    var showError = function(error) {
+        var overlay = document.getElementById('ink-error-overlay');
+        if (!overlay) {
+            overlay = document.createElement('div');
+            overlay.id = 'ink-error-overlay';
+            overlay.style.cssText = 'position:fixed;top:0;left:0;right:0;bottom:0;z-index:99999;overflow:auto;' +
+                'padding:24px;background:rgba(30,30,30,.95);color:#eee;font:13px/1.6 Menlo,Consolas,monospace;';
+            overlay.onclick = function(event) {
+                if (event.target === overlay) hideError();
+            };
+            document.body.appendChild(overlay);
+        }
+        var html = '<div style="color:#ff6b6b;font-size:16px;margin-bottom:8px;">Build failed</div>';
+        if (error.file) {
+            html += '<div style="color:#aaa;margin-bottom:8px;">' + escapeHTML(error.file + (error.line ? ':' + error.line : '')) + '</div>';
+        }
+        html += '<div style="white-space:pre-wrap;margin-bottom:16px;">' + escapeHTML(error.message) + '</div>';
+        if (error.excerpt) {
+            html += '<pre style="margin:0;padding:12px;background:#111;overflow:auto;">';
+            for (var i = 0; i < error.excerpt.length; i++) {
+                var line = error.excerpt[i];
+                var style = line.current ? 'background:#5c1f1f;display:block;' : 'display:block;';
+                html += '<span style="' + style + '"><span style="color:#777;">' + line.line + ' | </span>' + escapeHTML(line.text) + '</span>';
+            }
+            html += '</pre>';
+        }
+        overlay.innerHTML = html;
+    };
+    // Output path of current page, such as post/index.html
+    var currentPath = function() {
+        var path = decodeURIComponent(location.pathname);
+        if (root && path.indexOf(root) === 0) path = path.slice(root.length);
+        path = path.replace(/^\/+/, '');
+        if (path === '' || path.slice(-1) === '/') path += 'index.html';
+        return path;
+    };
+    var reloadCSS = function(path) {
+        var found = false;
+        var links = document.querySelectorAll('link[rel="stylesheet"]');
+        for (var i = 0; i < links.length; i++) {
+            var url = new URL(links[i].href, location.href);
+            var linkPath = decodeURIComponent(url.pathname);
+            if (root && linkPath.indexOf(root) === 0) linkPath = linkPath.slice(root.length);
+            if (linkPath.replace(/^\/+/, '') === path) {
+                url.searchParams.set('live', Date.now());
+                links[i].href = url.toString();
+                found = true;
+            }
+        }
+        return found;
+    };
+    var handleChange = function(paths) {
+        var page = currentPath();
+        var reload = false;
+        for (var i = 0; i < paths.length; i++) {
+            var path = paths[i];
+            if (/\.css$/i.test(path)) {
+                reloadCSS(path);
+            } else if (path === page || !/\.(html|xml|json)$/i.test(path)) {
+                reload = true;
+            }
+        }
+        if (reload) window.location.reload();
+    };
+    var connect = function() {
+        var conn = new WebSocket((location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + '/live');
+        conn.onmessage = function(event) {
+            var message = JSON.parse(event.data);
+            if (message.type === 'change') {
+                hideError();
+                handleChange(message.paths || []);
+            }
+            if (message.type === 'error') showError(message.error);
+        };
+        conn.onclose = function() {
+            if (connectTimer) clearTimeout(connectTimer);
+            connectTimer = setTimeout(connect, 1000);
+        };
+    };
+    connect();
+})();
+</script>`
+
+func (hub *LiveHub) add(client *liveClient) {
+	hub.Lock()
+	defer hub.Unlock()
+	hub.clients[client] = true
+	if hub.lastError != nil {
+		client.send <- hub.lastError
+	}
+}
+
+func (hub *LiveHub) remove(client *liveClient) {
+	hub.Lock()
+	defer hub.Unlock()
+	if _, ok := hub.clients[client]; ok {
+		delete(hub.clients, client)
+		close(client.send)
+	}
+}
+
+// Send message to all clients, slow clients are dropped
+func (hub *LiveHub) Broadcast(message LiveMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		Warn(err.Error())
+		return
+	}
+	hub.Lock()
+	defer hub.Unlock()
+	if message.Type == "error" {
+		hub.lastError = data
+	} else {
+		hub.lastError = nil
+	}
+	for client := range hub.clients {
+		select {
+		case client.send <- data:
+		default:
+			delete(hub.clients, client)
+			close(client.send)
+		}
+	}
+}
+
+// Show build error in browser overlay until next successful build
+func (hub *LiveHub) ReportError(err error) {
+	Error(err.Error())
+	buildErr := AsBuildError(err)
+	buildErr.ReadExcerpt()
+	hub.Broadcast(LiveMessage{Type: "error", Error: buildErr})
+}
+
+// Write queued messages, only this goroutine writes to the connection
+func (client *liveClient) writeLoop() {
+	defer client.conn.Close()
+	for data := range client.send {
+		if err := client.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			liveHub.remove(client)
+			return
+		}
+	}
+	client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+}
+
+// Read until the connection is closed by browser
+func (client *liveClient) readLoop() {
+	defer liveHub.remove(client)
+	for {
+		if _, _, err := client.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func Websocket(ctx *ink.Context) {
+	var upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+	if conn, err := upgrader.Upgrade(ctx.Res, ctx.Req, nil); err != nil {
+		Warn(err)
+	} else {
+		client := &liveClient{conn: conn, send: make(chan []byte, 16)}
+		liveHub.add(client)
+		go client.writeLoop()
+		go client.readLoop()
+	}
+	ctx.Stop()
+}
+
+// Insert live reload script before </body> of html page
+func InjectLiveReload(data []byte) []byte {
+	script := bytes.Replace([]byte(LIVE_RELOAD_SCRIPT), []byte("{{ROOT}}"), []byte(globalConfig.Site.Root), 1)
+	index := bytes.LastIndex(bytes.ToLower(data), []byte("</body>"))
+	if index < 0 {
+		return append(data, script...)
+	}
+	result := make([]byte, 0, len(data)+len(script))
+	result = append(result, data[:index]...)
+	result = append(result, script...)
+	return append(result, data[index:]...)
+}
+
+func hashFile(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	hash := fnv.New64a()
+	if _, err := io.Copy(hash, file); err != nil {
+		return 0, err
+	}
+	return hash.Sum64(), nil
+}
+
+// Hash outputs of last build and return paths changed since previous call
+func SnapshotOutputs() []string {
+	hashes := make(map[string]uint64)
+	changed := make([]string, 0)
+	for _, outPath := range buildPlan.Paths() {
+		hash, err := hashFile(filepath.Join(publicPath, outPath))
+		if err != nil {
+			continue
+		}
+		hashes[outPath] = hash
+		if oldHash, ok := outputHashes[outPath]; !ok || oldHash != hash {
+			changed = append(changed, filepath.ToSlash(outPath))
+		}
+	}
+	// Removed outputs
+	for outPath := range outputHashes {
+		if _, ok := hashes[outPath]; !ok {
+			changed = append(changed, filepath.ToSlash(outPath))
+		}
+	}
+	sort.Strings(changed)
+	outputHashes = hashes
+	return changed
+}
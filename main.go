@@ -2,20 +2,15 @@ package main
 
 import (
 	"bufio"
-	"fmt"
-	"github.com/BurntSushi/toml"
 	"golang.org/x/text/encoding/simplifiedchinese"
 	"html/template"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"strings"
 	"time"
 
-	"github.com/facebookgo/symwalk"
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v3"
 )
 
 type Charset string
@@ -82,6 +77,26 @@ func main() {
 	//app.Email = "imeoer@gmail.com"
 	app.Version = VERSION
 	app.Commands = []*cli.Command{
+		{
+			Name:      "init",
+			Usage:     "创建新博客",
+			ArgsUsage: "<dir>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "lang",
+					Value: "zh-cn",
+					Usage: "博客语言",
+				},
+				&cli.BoolFlag{
+					Name:  "vendor",
+					Usage: "复制内置主题到博客目录",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				Init(c)
+				return nil
+			},
+		},
 		{
 			Name:  "build",
 			Usage: "构建静态页面到public目录",
@@ -96,7 +111,9 @@ func main() {
 			Usage: "预览博客",
 			Action: func(c *cli.Context) error {
 				ParseGlobalConfigByCli(c, true)
-				Build()
+				if err := Build(); err != nil {
+					liveHub.ReportError(err)
+				}
 				Watch()
 				Serve()
 				return nil
@@ -116,15 +133,151 @@ func main() {
 			Name:  "serve",
 			Usage: "服务模式",
 			Action: func(c *cli.Context) error {
-				ParseGlobalConfigByCli(c, true)
+				ParseGlobalConfigByCli(c, false)
 				Build()
 				Serve()
 				return nil
 			},
 		},
 		{
-			Name:  "convert",
-			Usage: "转换 Jekyll/Hexo 格式到 Ink 格式 (Beta)",
+			Name:  "check",
+			Usage: "构建并检查失效链接",
+			Action: func(c *cli.Context) error {
+				ParseGlobalConfigByCli(c, false)
+				globalConfig.Build.Check = false
+				Build()
+				ReportBrokenLinks(CheckLinks())
+				return nil
+			},
+		},
+		{
+			Name:  "layouts",
+			Usage: "显示每个模板实际使用的文件",
+			Action: func(c *cli.Context) error {
+				ListLayouts(c)
+				return nil
+			},
+		},
+		{
+			Name:  "theme",
+			Usage: "管理主题",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "add",
+					Usage:     "从 git 仓库或 zip 安装主题",
+					ArgsUsage: "<git-url|zip>",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "ref", Usage: "git 分支、标签或提交"},
+						&cli.StringFlag{Name: "name", Usage: "主题名称"},
+						&cli.StringFlag{Name: "root", Usage: "博客根目录"},
+					},
+					Action: func(c *cli.Context) error {
+						ThemeAdd(c)
+						return nil
+					},
+				},
+				{
+					Name:  "list",
+					Usage: "列出已安装的主题",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "root", Usage: "博客根目录"},
+					},
+					Action: func(c *cli.Context) error {
+						ThemeList(c)
+						return nil
+					},
+				},
+				{
+					Name:      "update",
+					Usage:     "更新主题",
+					ArgsUsage: "[name...]",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "ref", Usage: "git 分支、标签或提交"},
+						&cli.StringFlag{Name: "root", Usage: "博客根目录"},
+					},
+					Action: func(c *cli.Context) error {
+						ThemeUpdate(c)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "config",
+			Usage: "检查配置文件",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "check",
+					Usage: "检查 config.yml、主题配置和文章头信息",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "root", Usage: "博客根目录"},
+					},
+					Action: func(c *cli.Context) error {
+						ConfigCheck(c)
+						return nil
+					},
+				},
+				{
+					Name:      "schema",
+					Usage:     "输出配置文件的 JSON Schema",
+					ArgsUsage: "[config|theme|article]",
+					Action: func(c *cli.Context) error {
+						ConfigSchema(c)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:      "share",
+			Usage:     "生成草稿的限时预览链接",
+			ArgsUsage: "<file>",
+			Flags: []cli.Flag{
+				&cli.DurationFlag{
+					Name:  "ttl",
+					Value: SHARE_DEFAULT_TTL,
+					Usage: "链接有效期",
+				},
+				&cli.StringFlag{
+					Name:  "root",
+					Value: ".",
+					Usage: "博客根目录",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				Share(c)
+				return nil
+			},
+		},
+		{
+			Name:      "import",
+			Usage:     "从 WordPress/Hugo/Ghost/Jekyll/Hexo 导入文章",
+			ArgsUsage: "<wordpress|hugo|ghost|jekyll|hexo> <path>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "root", Usage: "博客根目录"},
+			},
+			Action: func(c *cli.Context) error {
+				Import(c)
+				return nil
+			},
+		},
+		{
+			Name:  "export",
+			Usage: "导出文章、站点配置和作者为 JSON 或 zip 压缩包",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "输出文件，- 表示标准输出"},
+				&cli.StringFlag{Name: "format", Usage: "导出格式 json 或 zip"},
+				&cli.StringFlag{Name: "root", Usage: "博客根目录"},
+			},
+			Action: func(c *cli.Context) error {
+				Export(c)
+				return nil
+			},
+		},
+		{
+			Name:      "convert",
+			Usage:     "转换 Jekyll/Hexo 格式到 Ink 格式 (Beta)",
+			ArgsUsage: "<source> [target]",
 			Action: func(c *cli.Context) error {
 				Convert(c)
 				return nil
@@ -180,6 +333,10 @@ func main() {
 					Usage: "文件路径",
 				},
 
+				&cli.StringFlag{
+					Name:  "root",
+					Usage: "博客根目录，默认向上查找 config.yml",
+				},
 				&cli.StringSliceFlag{
 					Name:  "tag",
 					Usage: "文章标签",
@@ -195,6 +352,13 @@ func main() {
 	os.Exit(exitCode)
 }
 
+// Stop if there are more args than expected, cli does not parse flags after args
+func CheckArgs(c *cli.Context, max int) {
+	if c.Args().Len() > max {
+		Fatal("Unexpected argument " + c.Args().Get(max) + ", please put flags before arguments")
+	}
+}
+
 func ParseGlobalConfigByCli(c *cli.Context, develop bool) {
 	if c.Args().Len() > 0 {
 		rootPath = c.Args().Slice()[0]
@@ -219,15 +383,15 @@ func ParseGlobalConfigWrap(root string, develop bool) {
 }
 
 func New(c *cli.Context) {
-	// If source folder does not exist, create
-	if _, err := os.Stat("source/"); os.IsNotExist(err) {
-		os.Mkdir("source", os.ModePerm)
-	}
-	if _, err := os.Stat("source/page"); os.IsNotExist(err) {
-		os.Mkdir("source/page", os.ModePerm)
+	root := c.String("root")
+	if root == "" {
+		root = FindRootPath()
 	}
-	if _, err := os.Stat("source/post"); os.IsNotExist(err) {
-		os.Mkdir("source/post", os.ModePerm)
+	// If source folder does not exist, create
+	for _, folder := range []string{"page", "post"} {
+		if err := os.MkdirAll(filepath.Join(root, "source", folder), os.ModePerm); err != nil {
+			Fatal(err)
+		}
 	}
 
 	var author, blogTitle, fileName string
@@ -299,7 +463,7 @@ func New(c *cli.Context) {
 		cover = c.String("cover")
 	}
 
-	var filePath = "source/" + postType + "/" + fileName
+	var filePath = filepath.Join(root, "source", postType, fileName)
 	file, err := os.Create(filePath)
 	if err != nil {
 		Fatal(err)
@@ -401,117 +565,3 @@ func Publish() {
 	// Exec command
 	cmd.Run()
 }
-
-func Convert(c *cli.Context) {
-	// Parse arguments
-	var sourcePath, rootPath string
-	args := c.Args()
-	if args.Len() > 0 {
-		sourcePath = args.Slice()[0]
-	} else {
-		Fatal("Please specify the posts source path")
-	}
-	if args.Len() > 1 {
-		rootPath = args.Slice()[1]
-	} else {
-		rootPath = "."
-	}
-	// Check if path exist
-	if !Exists(sourcePath) || !Exists(rootPath) {
-		Fatal("Please specify valid path")
-	}
-	// Parse Jekyll/Hexo post file
-	count := 0
-	symwalk.Walk(sourcePath, func(path string, f os.FileInfo, err error) error {
-		fileExt := strings.ToLower(filepath.Ext(path))
-		if fileExt == ".md" || fileExt == ".html" {
-			// Read data from file
-			data, err := os.ReadFile(path)
-			fileName := filepath.Base(path)
-			Log("转换中 " + fileName)
-			if err != nil {
-				Fatal(err.Error())
-			}
-			// Split config and markdown
-			var configStr, contentStr string
-			content := strings.TrimSpace(string(data))
-			var sep = "---"
-			if strings.Contains(string(data), "+++") {
-				sep = "+++"
-			}
-			parseAry := strings.SplitN(content, sep, 3)
-			parseLen := len(parseAry)
-			if parseLen == 3 { // Jekyll
-				configStr = parseAry[1]
-				contentStr = parseAry[2]
-			} else if parseLen == 2 { // Hexo
-				configStr = parseAry[0]
-				contentStr = parseAry[1]
-			}
-			// Parse config
-			var article ArticleConfig
-			if sep == "+++" {
-				if err = toml.Unmarshal([]byte(configStr), &article); err != nil {
-					Fatal(err.Error())
-				}
-			} else {
-				if err = yaml.Unmarshal([]byte(configStr), &article); err != nil {
-					Fatal(err.Error())
-				}
-			}
-
-			tags := make(map[string]bool)
-			for _, t := range article.Tags {
-				tags[t] = true
-			}
-			for _, c := range article.Categories {
-				if _, ok := tags[c]; !ok {
-					article.Tags = append(article.Tags, c)
-				}
-			}
-			if article.Author == "" {
-				article.Author = "me"
-			}
-			// Convert date
-			//"2006-01-02T15:04:05Z07:00"
-			tf1 := "2006-01-02T15:04:05Z08:00"
-			tf2 := "2006-01-02 15:04:05"
-			tf3 := "2006-01-02 15:04:05 +0800"
-			tf4 := "2006-01-02"
-			if t, err := time.Parse(tf1, article.Date); err != nil {
-				if _, err := time.Parse(tf2, article.Date); err != nil {
-					if _, err := time.Parse(tf3, article.Date); err != nil {
-						if _, err := time.Parse(tf4, article.Date); err != nil {
-						} else {
-							article.Date += " 00:00:00"
-						}
-					} else {
-
-					}
-				} else {
-
-				}
-			} else {
-				article.Date = t.Format(tf3)
-			}
-
-			article.Update = ""
-			// Generate Config
-			var inkConfig []byte
-			if inkConfig, err = yaml.Marshal(article); err != nil {
-				Fatal(err.Error())
-			}
-			inkConfigStr := string(inkConfig)
-			markdownStr := inkConfigStr + "\n\n---\n\n" + contentStr + "\n"
-			targetName := fileName
-			if fileExt != ".md" {
-				targetName = targetName + ".md"
-			}
-
-			os.WriteFile(filepath.Join(rootPath, targetName), []byte(markdownStr), 0644)
-			count++
-		}
-		return nil
-	})
-	fmt.Printf("\nConvert finish, total %v articles\n", count)
-}
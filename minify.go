@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/json"
+	"github.com/tdewolff/minify/v2/svg"
+)
+
+type MinifyConfig struct {
+	Html bool
+	Css  bool
+	Js   bool
+	Svg  bool
+	Json bool
+}
+
+// Bytes saved by minifying
+type MinifyStats struct {
+	sync.Mutex
+	Files  int
+	Before int
+	After  int
+}
+
+var minifier *minify.M
+var minifyTypes map[string]string
+var minifyStats MinifyStats
+
+// Setup minifier by enabled file types
+func InitMinifier(config MinifyConfig) {
+	minifier = nil
+	minifyTypes = make(map[string]string)
+	minifyStats = MinifyStats{}
+	m := minify.New()
+	if config.Html {
+		// Keep optional tags and quotes so that themes relying on them still work
+		m.Add("text/html", &html.Minifier{
+			KeepDocumentTags:    true,
+			KeepEndTags:         true,
+			KeepQuotes:          true,
+			KeepDefaultAttrVals: true,
+			KeepSpecialComments: true,
+		})
+		minifyTypes[".html"] = "text/html"
+		minifyTypes[".htm"] = "text/html"
+	}
+	if config.Css {
+		m.AddFunc("text/css", css.Minify)
+		minifyTypes[".css"] = "text/css"
+	}
+	if config.Js {
+		m.AddFunc("application/javascript", js.Minify)
+		minifyTypes[".js"] = "application/javascript"
+	}
+	if config.Svg {
+		m.AddFunc("image/svg+xml", svg.Minify)
+		minifyTypes[".svg"] = "image/svg+xml"
+	}
+	if config.Json {
+		m.AddFunc("application/json", json.Minify)
+		minifyTypes[".json"] = "application/json"
+	}
+	if len(minifyTypes) > 0 {
+		minifier = m
+	}
+}
+
+// Check if file should be minified by its extension
+func ShouldMinify(path string) bool {
+	if minifier == nil {
+		return false
+	}
+	_, ok := minifyTypes[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Minify data by file extension, original data is returned on failure
+func MinifyBytes(path string, data []byte) []byte {
+	mediaType := minifyTypes[strings.ToLower(filepath.Ext(path))]
+	var out bytes.Buffer
+	if err := minifier.Minify(mediaType, &out, bytes.NewReader(data)); err != nil {
+		Warn("Minify " + path + ": " + err.Error())
+		return data
+	}
+	minifyStats.Lock()
+	minifyStats.Files++
+	minifyStats.Before += len(data)
+	minifyStats.After += out.Len()
+	minifyStats.Unlock()
+	return out.Bytes()
+}
+
+// Print bytes saved by minifying
+func ReportMinifyStats() {
+	if minifier == nil || minifyStats.Files == 0 {
+		return
+	}
+	saved := minifyStats.Before - minifyStats.After
+	Log(fmt.Sprintf("Minified %d files, saved %.1f KB (%.1f%%)",
+		minifyStats.Files, float64(saved)/1024, float64(saved)*100/float64(minifyStats.Before)))
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"html/template"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -27,7 +30,18 @@ type SiteConfig struct {
 	Lang     string
 	Url      string
 	Link     string
-	Config   interface{}
+	// Permalink pattern per article type, e.g. post or page
+	Permalinks map[string]string
+	// Output dir/index.html instead of name.html
+	Pretty bool
+	// Table of contents of articles
+	Toc TocConfig
+	// Reading time and summary of articles
+	Reading ReadingConfig
+	// Default SEO data of pages
+	Seo SeoConfig
+	// Custom variables used by themes
+	Config map[string]interface{}
 }
 
 type AuthorConfig struct {
@@ -39,40 +53,64 @@ type AuthorConfig struct {
 
 type BuildConfig struct {
 	Output   string
+	Host     string
 	Port     string
 	Watch    bool
 	Copy     []string
 	Publish  string
 	PublishW string
+	// Check broken links after build
+	Check bool
+	// Minify output by file type
+	Minify MinifyConfig
+	// Write precompressed files alongside outputs
+	Compress CompressConfig
+	// Cache-Control header of ink serve by file extension
+	Cache map[string]string
+	// Secret to sign draft share links
+	Secret string
+	// Read dates, commit and author of articles from git history
+	GitInfo bool
+	// Social cards of articles without cover
+	Card CardConfig
 }
 
 type GlobalConfig struct {
-	I18n    map[string]string
+	// Texts of site language from theme
+	I18n    map[string]string `yaml:"-"`
 	Site    SiteConfig
 	Authors map[string]AuthorConfig
 	Build   BuildConfig
-	Develop bool
+	// Comment system of articles
+	Comments CommentsConfig
+	Develop  bool `yaml:"-"`
 }
 
 // ArticleConfig 文章配置
 type ArticleConfig struct {
-	Title      string   //标题
-	Date       string   //日期
-	Update     string   //更新日期
-	Author     string   //作者
-	Tags       []string //标签
-	Categories []string //分类
-	Topic      string   //主题
-	Cover      string   //封面
-	Draft      bool     //草稿
-	Preview    template.HTML
-	Top        bool                   //置顶
-	Type       string                 //类型
-	Hide       bool                   //隐藏
-	Toc        bool                   //目录
-	Image      string                 //图片
-	Subtitle   string                 //子标题
-	Config     map[string]interface{} //其他配置
+	Title          string   //标题
+	Date           string   //日期
+	Update         string   //更新日期
+	Author         string   //作者
+	Tags           []string //标签
+	Categories     []string //分类
+	Topic          string   //主题
+	Cover          string   //封面
+	Draft          bool     //草稿
+	Preview        template.HTML
+	Top            bool                   //置顶
+	Type           string                 //类型
+	Hide           bool                   //隐藏
+	Toc            bool                   //目录
+	Image          string                 //图片
+	Subtitle       string                 //子标题
+	Slug           string                 //链接名称
+	Url            string                 //自定义链接
+	Aliases        []string               //旧链接
+	Layout         string                 //布局模板
+	Seo            SeoConfig              //搜索引擎优化
+	EnableComments *bool                  `yaml:"comments"` //评论
+	Config         map[string]interface{} //其他配置
 }
 
 type Article struct {
@@ -92,58 +130,68 @@ type Article struct {
 	Config   interface{}
 	Image    string
 	Subtitle string
+	// Headings of content
+	TableOfContents []*TocItem
+	// Words of content, each CJK character is a word
+	WordCount int
+	// Reading time in minutes
+	ReadingTime int
+	// Plain text of preview, or beginning of content without preview
+	Summary string
+	// Last commit of source file, nil if git info is disabled
+	GitInfo *GitInfo
+	// Open Graph, Twitter card and JSON-LD data
+	PageSeo *PageSeo
+	// Template file rendering the article
+	tplPath string
+	// Output path of generated card, empty if article has cover
+	cardPath string
 }
 
 type ThemeConfig struct {
 	Copy []string
 	Lang map[string]map[string]string
+	// Parent theme directory relative to this theme
+	Extends string
+	// Theme directories from this theme to its parents
+	Chain []string `yaml:"-"`
 }
 
 const (
-	CONFIG_SPLIT = "---"
-	MORE_SPLIT   = "<!--more-->"
+	DEFAULT_LIMIT = 10
+	CONFIG_SPLIT  = "---"
+	MORE_SPLIT    = "<!--more-->"
 )
 
-// Modify the rendering of the image node to use jQuery-unveil to lazy load images.
-//
-// Note: This is a hacky way to do this, but it works for now.
-// LazyLoadImages in markdown.html.Flags should not be used with this hook, and
-// adding the absolute path prefix to the image destination (which is what the original parser does)
-// is not implemented yet.
-func renderHookLazyLoadImage(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
-	// Skip all nodes that are not Image nodes
-	if _, ok := node.(*ast.Image); !ok {
-		return ast.GoToNext, false
-	}
-	img := node.(*ast.Image)
-	if entering {
-		if img.Attribute == nil {
-			img.Attribute = &ast.Attribute{}
-		}
-		if img.Attrs == nil {
-			img.Attrs = make(map[string][]byte)
-		}
-		img.Attrs["data-src"] = img.Destination
-		img.Destination = []byte("data:image/gif;base64,R0lGODlhGAAYAPQAAP///wAAAM7Ozvr6+uDg4LCwsOjo6I6OjsjIyJycnNjY2KioqMDAwPLy8nd3d4aGhri4uGlpaQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAACH5BAkHAAAAIf4aQ3JlYXRlZCB3aXRoIGFqYXhsb2FkLmluZm8AIf8LTkVUU0NBUEUyLjADAQAAACwAAAAAGAAYAAAFriAgjiQAQWVaDgr5POSgkoTDjFE0NoQ8iw8HQZQTDQjDn4jhSABhAAOhoTqSDg7qSUQwxEaEwwFhXHhHgzOA1xshxAnfTzotGRaHglJqkJcaVEqCgyoCBQkJBQKDDXQGDYaIioyOgYSXA36XIgYMBWRzXZoKBQUMmil0lgalLSIClgBpO0g+s26nUWddXyoEDIsACq5SsTMMDIECwUdJPw0Mzsu0qHYkw72bBmozIQAh+QQJBwAAACwAAAAAGAAYAAAFsCAgjiTAMGVaDgR5HKQwqKNxIKPjjFCk0KNXC6ATKSI7oAhxWIhezwhENTCQEoeGCdWIPEgzESGxEIgGBWstEW4QCGGAIJEoxGmGt5ZkgCRQQHkGd2CESoeIIwoMBQUMP4cNeQQGDYuNj4iSb5WJnmeGng0CDGaBlIQEJziHk3sABidDAHBgagButSKvAAoyuHuUYHgCkAZqebw0AgLBQyyzNKO3byNuoSS8x8OfwIchACH5BAkHAAAALAAAAAAYABgAAAW4ICCOJIAgZVoOBJkkpDKoo5EI43GMjNPSokXCINKJCI4HcCRIQEQvqIOhGhBHhUTDhGo4diOZyFAoKEQDxra2mAEgjghOpCgz3LTBIxJ5kgwMBShACREHZ1V4Kg1rS44pBAgMDAg/Sw0GBAQGDZGTlY+YmpyPpSQDiqYiDQoCliqZBqkGAgKIS5kEjQ21VwCyp76dBHiNvz+MR74AqSOdVwbQuo+abppo10ssjdkAnc0rf8vgl8YqIQAh+QQJBwAAACwAAAAAGAAYAAAFrCAgjiQgCGVaDgZZFCQxqKNRKGOSjMjR0qLXTyciHA7AkaLACMIAiwOC1iAxCrMToHHYjWQiA4NBEA0Q1RpWxHg4cMXxNDk4OBxNUkPAQAEXDgllKgMzQA1pSYopBgonCj9JEA8REQ8QjY+RQJOVl4ugoYssBJuMpYYjDQSliwasiQOwNakALKqsqbWvIohFm7V6rQAGP6+JQLlFg7KDQLKJrLjBKbvAor3IKiEAIfkECQcAAAAsAAAAABgAGAAABbUgII4koChlmhokw5DEoI4NQ4xFMQoJO4uuhignMiQWvxGBIQC+AJBEUyUcIRiyE6CR0CllW4HABxBURTUw4nC4FcWo5CDBRpQaCoF7VjgsyCUDYDMNZ0mHdwYEBAaGMwwHDg4HDA2KjI4qkJKUiJ6faJkiA4qAKQkRB3E0i6YpAw8RERAjA4tnBoMApCMQDhFTuySKoSKMJAq6rD4GzASiJYtgi6PUcs9Kew0xh7rNJMqIhYchACH5BAkHAAAALAAAAAAYABgAAAW0ICCOJEAQZZo2JIKQxqCOjWCMDDMqxT2LAgELkBMZCoXfyCBQiFwiRsGpku0EshNgUNAtrYPT0GQVNRBWwSKBMp98P24iISgNDAS4ipGA6JUpA2WAhDR4eWM/CAkHBwkIDYcGiTOLjY+FmZkNlCN3eUoLDmwlDW+AAwcODl5bYl8wCVYMDw5UWzBtnAANEQ8kBIM0oAAGPgcREIQnVloAChEOqARjzgAQEbczg8YkWJq8nSUhACH5BAkHAAAALAAAAAAYABgAAAWtICCOJGAYZZoOpKKQqDoORDMKwkgwtiwSBBYAJ2owGL5RgxBziQQMgkwoMkhNqAEDARPSaiMDFdDIiRSFQowMXE8Z6RdpYHWnEAWGPVkajPmARVZMPUkCBQkJBQINgwaFPoeJi4GVlQ2Qc3VJBQcLV0ptfAMJBwdcIl+FYjALQgimoGNWIhAQZA4HXSpLMQ8PIgkOSHxAQhERPw7ASTSFyCMMDqBTJL8tf3y2fCEAIfkECQcAAAAsAAAAABgAGAAABa8gII4k0DRlmg6kYZCoOg5EDBDEaAi2jLO3nEkgkMEIL4BLpBAkVy3hCTAQKGAznM0AFNFGBAbj2cA9jQixcGZAGgECBu/9HnTp+FGjjezJFAwFBQwKe2Z+KoCChHmNjVMqA21nKQwJEJRlbnUFCQlFXlpeCWcGBUACCwlrdw8RKGImBwktdyMQEQciB7oACwcIeA4RVwAODiIGvHQKERAjxyMIB5QlVSTLYLZ0sW8hACH5BAkHAAAALAAAAAAYABgAAAW0ICCOJNA0ZZoOpGGQrDoOBCoSxNgQsQzgMZyIlvOJdi+AS2SoyXrK4umWPM5wNiV0UDUIBNkdoepTfMkA7thIECiyRtUAGq8fm2O4jIBgMBA1eAZ6Knx+gHaJR4QwdCMKBxEJRggFDGgQEREPjjAMBQUKIwIRDhBDC2QNDDEKoEkDoiMHDigICGkJBS2dDA6TAAnAEAkCdQ8ORQcHTAkLcQQODLPMIgIJaCWxJMIkPIoAt3EhACH5BAkHAAAALAAAAAAYABgAAAWtICCOJNA0ZZoOpGGQrDoOBCoSxNgQsQzgMZyIlvOJdi+AS2SoyXrK4umWHM5wNiV0UN3xdLiqr+mENcWpM9TIbrsBkEck8oC0DQqBQGGIz+t3eXtob0ZTPgNrIwQJDgtGAgwCWSIMDg4HiiUIDAxFAAoODwxDBWINCEGdSTQkCQcoegADBaQ6MggHjwAFBZUFCm0HB0kJCUy9bAYHCCPGIwqmRq0jySMGmj6yRiEAIfkECQcAAAAsAAAAABgAGAAABbIgII4k0DRlmg6kYZCsOg4EKhLE2BCxDOAxnIiW84l2L4BLZKipBopW8XRLDkeCiAMyMvQAA+uON4JEIo+vqukkKQ6RhLHplVGN+LyKcXA4Dgx5DWwGDXx+gIKENnqNdzIDaiMECwcFRgQCCowiCAcHCZIlCgICVgSfCEMMnA0CXaU2YSQFoQAKUQMMqjoyAglcAAyBAAIMRUYLCUkFlybDeAYJryLNk6xGNCTQXY0juHghACH5BAkHAAAALAAAAAAYABgAAAWzICCOJNA0ZVoOAmkY5KCSSgSNBDE2hDyLjohClBMNij8RJHIQvZwEVOpIekRQJyJs5AMoHA+GMbE1lnm9EcPhOHRnhpwUl3AsknHDm5RN+v8qCAkHBwkIfw1xBAYNgoSGiIqMgJQifZUjBhAJYj95ewIJCQV7KYpzBAkLLQADCHOtOpY5PgNlAAykAEUsQ1wzCgWdCIdeArczBQVbDJ0NAqyeBb64nQAGArBTt8R8mLuyPyEAOw==")
-	} else {
-		w.Write([]byte("\" data-src=\""))
-		w.Write(img.Attrs["data-src"])
-	}
-	return ast.GoToNext, false
+func ParseMarkdown(markdown string, toc bool) template.HTML {
+	content, _ := ParseMarkdownToc(markdown, toc)
+	return content
 }
 
-func ParseMarkdown(markdown string, toc bool) template.HTML {
+// Render markdown and get its table of contents, which is prepended to content if toc is set
+func ParseMarkdownToc(markdown string, toc bool) (template.HTML, []*TocItem) {
 	extensions := parser.CommonExtensions | parser.Footnotes
-	parser := parser.NewWithExtensions(extensions)
-
-	htmlFlags := html.CommonFlags
+	if !toc {
+		extensions |= parser.AutoHeadingIDs
+	}
+	doc := parser.NewWithExtensions(extensions).Parse(gomk.NormalizeNewlines([]byte(markdown)))
 	if toc {
-		htmlFlags |= html.TOC
+		// Articles with toc keep the anchors of earlier versions
+		numberHeadingIDs(doc)
 	}
-	opts := html.RendererOptions{Flags: htmlFlags, RenderNodeHook: renderHookLazyLoadImage}
-	renderer := html.NewRenderer(opts)
+	tocItems := TableOfContents(doc)
+
+	var renderer *html.Renderer
+	opts := html.RendererOptions{Flags: html.CommonFlags, RenderNodeHook: func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		return RenderHookNode(w, node, entering, renderer)
+	}}
+	renderer = html.NewRenderer(opts)
 
-	return template.HTML(gomk.ToHTML(gomk.NormalizeNewlines([]byte(markdown)), parser, renderer))
+	content := string(gomk.Render(doc, renderer))
+	if toc && TocInline() {
+		content = RenderToc(tocItems) + content
+	}
+	return template.HTML(content), tocItems
 }
 
 func ReplaceRootFlag(content string) string {
@@ -157,11 +205,15 @@ func ParseGlobalConfig(configPath string, develop bool) (*GlobalConfig, *ThemeCo
 	if err != nil {
 		return nil, nil
 	}
+	CheckYaml(configPath, 0, data, GlobalConfig{}).Report()
 	if err = yaml.Unmarshal(data, &config); err != nil {
-		Fatal(err.Error())
+		BuildFatal(NewYamlError(configPath, 0, err))
+	}
+	if config == nil {
+		config = &GlobalConfig{}
 	}
 	if config.Site.Config == nil {
-		config.Site.Config = ""
+		config.Site.Config = make(map[string]interface{})
 	}
 	config.Develop = develop
 	if develop {
@@ -171,14 +223,21 @@ func ParseGlobalConfig(configPath string, develop bool) (*GlobalConfig, *ThemeCo
 	if config.Site.Url != "" && strings.HasSuffix(config.Site.Url, "/") {
 		config.Site.Url = strings.TrimSuffix(config.Site.Url, "/")
 	}
+	if config.Site.Limit <= 0 {
+		config.Site.Limit = DEFAULT_LIMIT
+	}
 	if config.Build.Output == "" {
 		config.Build.Output = "public"
 	}
+	if err := ValidateComments(config); err != nil {
+		BuildFatal(&BuildError{File: configPath, Line: yamlKeyLine(data, "comments"), Message: err.Error()})
+	}
 	// Parse Theme Config
-	themeConfig := ParseThemeConfig(filepath.Join(rootPath, config.Site.Theme, "config.yml"))
-	for _, copyItem := range themeConfig.Copy {
-		config.Build.Copy = append(config.Build.Copy, filepath.Join(config.Site.Theme, copyItem))
+	themeDir := filepath.Join(rootPath, config.Site.Theme)
+	if config.Site.Theme == "" {
+		themeDir = BuiltinThemePath()
 	}
+	themeConfig := ParseThemeChain(themeDir)
 	config.I18n = make(map[string]string)
 	for item, langItem := range themeConfig.Lang {
 		config.I18n[item] = langItem[config.Site.Lang]
@@ -190,12 +249,17 @@ func ParseThemeConfig(configPath string) *ThemeConfig {
 	// Read data from file
 	var themeConfig *ThemeConfig
 	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		// Theme without config.yml uses defaults
+		return nil
+	}
 	if err != nil {
-		Fatal(err.Error())
+		BuildFatal(err.Error())
 	}
 	// Parse config content
+	CheckYaml(configPath, 0, data, ThemeConfig{}).Report()
 	if err := yaml.Unmarshal(data, &themeConfig); err != nil {
-		Fatal(err.Error())
+		BuildFatal(NewYamlError(configPath, 0, err))
 	}
 	return themeConfig
 }
@@ -205,7 +269,7 @@ func ParseArticleConfig(markdownPath string) (config *ArticleConfig, content str
 	// Read data from file
 	data, err := os.ReadFile(markdownPath)
 	if err != nil {
-		Fatal(err.Error())
+		BuildFatal(err.Error())
 	}
 	// Split config and markdown
 	contentStr := string(data)
@@ -219,8 +283,16 @@ func ParseArticleConfig(markdownPath string) (config *ArticleConfig, content str
 		content = markdownStr[1]
 	}
 	// Parse config content
+	issues := CheckYaml(markdownPath, 0, []byte(configStr), ArticleConfig{})
+	for _, warning := range issues.Warnings {
+		Warn(warning.Error())
+	}
+	if len(issues.Errors) > 0 {
+		ReportBuildError(issues.Errors[0])
+		return nil, ""
+	}
 	if err := yaml.Unmarshal([]byte(configStr), &config); err != nil {
-		Error(err.Error())
+		ReportBuildError(NewYamlError(markdownPath, 0, err))
 		return nil, ""
 	}
 	if config == nil {
@@ -232,7 +304,7 @@ func ParseArticleConfig(markdownPath string) (config *ArticleConfig, content str
 	// Parse preview splited by MORE_SPLIT
 	previewAry := strings.SplitN(content, MORE_SPLIT, 2)
 	if len(config.Preview) <= 0 && len(previewAry) > 1 {
-		config.Preview = ParseMarkdown(previewAry[0], false)
+		config.Preview, _ = ParseArticleMarkdown(markdownPath, config, previewAry[0], false)
 		content = strings.Replace(content, MORE_SPLIT, "", 1)
 	} else {
 		config.Preview = ParseMarkdown(string(config.Preview), false)
@@ -240,6 +312,23 @@ func ParseArticleConfig(markdownPath string) (config *ArticleConfig, content str
 	return config, content
 }
 
+// Prepare templates and git history used by ParseArticle outside of build
+func InitArticleParser() {
+	sourcePath = filepath.Join(rootPath, "source")
+	templateDirs = TemplateDirs(rootPath, themeConfig)
+	funcCxt := FuncContext{
+		rootPath:   rootPath,
+		themePath:  themeConfig.Chain[0],
+		global:     globalConfig,
+		currentCwd: themeConfig.Chain[0],
+	}
+	shortcodeTpls = NewThemeTpls(SHORTCODES_DIR, nil, funcCxt)
+	renderHookTpls = NewThemeTpls("", nil, funcCxt)
+	if globalConfig.Build.GitInfo {
+		gitHistory = LoadGitHistory(rootPath)
+	}
+}
+
 func ParseArticle(markdownPath string) *Article {
 	config, content := ParseArticleConfig(markdownPath)
 	if config == nil {
@@ -256,21 +345,44 @@ func ParseArticle(markdownPath string) *Article {
 	article.Preview = config.Preview
 	article.Config = config.Config
 	article.Markdown = content
-	article.Content = ParseMarkdown(content, config.Toc)
+	article.Content, article.TableOfContents = ParseArticleMarkdown(markdownPath, config, content, config.Toc)
+	contentText := HTMLText(article.Content)
+	article.WordCount = WordCount(contentText)
+	article.ReadingTime = ReadingTime(article.WordCount)
+	if article.Preview != "" {
+		article.Summary = HTMLText(article.Preview)
+	} else {
+		article.Summary = Summarize(contentText)
+	}
 	if config.Date != "" {
-		article.Time = ParseDate(config.Date)
+		article.Time = parseArticleDate(markdownPath, "date", config.Date)
 		article.Date = article.Time.Unix()
 	}
 	if config.Update != "" {
-		article.MTime = ParseDate(config.Update)
+		article.MTime = parseArticleDate(markdownPath, "update", config.Update)
 		article.Update = article.MTime.Unix()
 	}
+	// Dates of front matter win over git history
+	if gitInfo := GitInfoOf(markdownPath); gitInfo != nil {
+		article.GitInfo = gitInfo
+		if config.Date == "" {
+			article.Time = gitInfo.Created
+			article.Date = article.Time.Unix()
+		}
+		if config.Update == "" {
+			article.MTime = gitInfo.LastMod
+			article.Update = article.MTime.Unix()
+		}
+	}
 	article.Title = config.Title
 	article.Topic = config.Topic
 	article.Draft = config.Draft
 	article.Top = config.Top
 	article.Image = config.Image
 	article.Subtitle = config.Subtitle
+	article.Layout = config.Layout
+	article.EnableComments = config.EnableComments
+	article.Aliases = config.Aliases
 	if author, ok := globalConfig.Authors[config.Author]; ok {
 		author.Id = config.Author
 		author.Avatar = ReplaceRootFlag(author.Avatar)
@@ -300,28 +412,118 @@ func ParseArticle(markdownPath string) *Article {
 	// Generate page name
 	fileName := strings.TrimSuffix(strings.ToLower(filepath.Base(markdownPath)), ".md")
 	link := fileName + ".html"
-	// Genetate custom link
 	if article.Type == "post" {
 		datePrefix := article.Time.Format("2006-01-02-")
 		fileName = strings.TrimPrefix(fileName, datePrefix)
-		if globalConfig.Site.Link != "" {
-			linkMap := map[string]string{
-				"{year}":     article.Time.Format("2006"),
-				"{month}":    article.Time.Format("01"),
-				"{day}":      article.Time.Format("02"),
-				"{hour}":     article.Time.Format("15"),
-				"{minute}":   article.Time.Format("04"),
-				"{second}":   article.Time.Format("05"),
-				"{category}": article.Category,
-				"{title}":    fileName,
-			}
-			link = globalConfig.Site.Link
-			for key, val := range linkMap {
-				link = strings.Replace(link, key, val, -1)
-			}
+	}
+	slug := ArticleSlug(config, fileName)
+	if config.Slug != "" {
+		link = slug + ".html"
+	}
+	// Genetate custom link
+	pattern := globalConfig.Site.Permalinks[article.Type]
+	if pattern == "" && article.Type == "post" {
+		pattern = globalConfig.Site.Link
+	}
+	if pattern != "" {
+		linkMap := map[string]string{
+			"{year}":     article.Time.Format("2006"),
+			"{month}":    article.Time.Format("01"),
+			"{day}":      article.Time.Format("02"),
+			"{hour}":     article.Time.Format("15"),
+			"{minute}":   article.Time.Format("04"),
+			"{second}":   article.Time.Format("05"),
+			"{category}": article.Category,
+			"{title}":    fileName,
+			"{slug}":     slug,
+		}
+		link = pattern
+		for key, val := range linkMap {
+			link = strings.Replace(link, key, val, -1)
+		}
+	}
+	if globalConfig.Site.Pretty {
+		link = PrettyLink(link)
+	}
+	// Custom url is used as it is
+	if config.Url != "" {
+		link = strings.TrimPrefix(config.Url, "/")
+		// Url without extension is a folder like pretty url
+		if link != "" && !strings.HasSuffix(link, "/") && path.Ext(link) == "" {
+			link += "/"
 		}
 	}
 	article.Link = link
 	article.GlobalConfig = *globalConfig
+	// Cards of drafts are not generated
+	if article.Cover == "" && globalConfig.Build.Card.Enable && !article.Draft {
+		article.cardPath = CardPath(link)
+		article.Cover = globalConfig.Site.Root + "/" + filepath.ToSlash(article.cardPath)
+	}
+	article.PageSeo = ArticleSeo(&article, config)
 	return &article
 }
+
+// Parse date field of article, error is located at the field line
+func parseArticleDate(markdownPath string, field string, dateStr string) time.Time {
+	date, err := ParseDateString(dateStr)
+	if err != nil {
+		BuildFatal(&BuildError{File: markdownPath, Line: fieldLine(markdownPath, field), Message: err.Error()})
+	}
+	return date
+}
+
+// Get line number of front matter field, 0 if not found
+func fieldLine(markdownPath string, field string) int {
+	data, err := os.ReadFile(markdownPath)
+	if err != nil {
+		return 0
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, field+":") {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Get slug of article, fallback to the title when file name is not ASCII, then to hash of file name
+func ArticleSlug(config *ArticleConfig, fileName string) string {
+	if config.Slug != "" {
+		return strings.Trim(config.Slug, "/")
+	}
+	slug := ""
+	if IsASCII(fileName) {
+		slug = Slugify(fileName)
+	} else {
+		slug = Slugify(config.Title)
+	}
+	if slug == "" {
+		// Stable id of file when neither name nor title has ASCII letters
+		hash := sha1.Sum([]byte(fileName))
+		slug = hex.EncodeToString(hash[:4])
+	}
+	return slug
+}
+
+// Convert name.html to name/ for pretty url
+func PrettyLink(link string) string {
+	if link == "" || strings.HasSuffix(link, "/") {
+		return link
+	}
+	if strings.HasSuffix(link, "/index.html") || link == "index.html" {
+		return strings.TrimSuffix(link, "index.html")
+	}
+	if strings.HasSuffix(link, ".html") {
+		return strings.TrimSuffix(link, ".html") + "/"
+	}
+	return link
+}
+
+// Get output file path of link relative to public folder
+func LinkPath(link string) string {
+	if link == "" || strings.HasSuffix(link, "/") {
+		link += "index.html"
+	}
+	return filepath.FromSlash(link)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/facebookgo/symwalk"
+)
+
+// Output files planned by build
+type BuildPlan struct {
+	// Output path relative to public folder -> source which generates it
+	Outputs map[string]string
+	// Precompressed files written after build
+	Compressed []string
+	Collisions int
+}
+
+// Static file copied to public folder
+type CopyItem struct {
+	Source string
+	Target string
+}
+
+var buildPlan *BuildPlan
+
+func NewBuildPlan() *BuildPlan {
+	return &BuildPlan{
+		Outputs: make(map[string]string),
+	}
+}
+
+// Add output path of source, return false if it is already used, which is reported unless by the same source
+func (plan *BuildPlan) Add(outPath string, source string) bool {
+	outPath = filepath.Clean(outPath)
+	if usedSource, ok := plan.Outputs[outPath]; ok {
+		if usedSource == source {
+			return false
+		}
+		Error("Output path collision " + outPath + ": " + usedSource + " and " + source)
+		plan.Collisions++
+		return false
+	}
+	plan.Outputs[outPath] = source
+	return true
+}
+
+// Check if output is planned to be generated by source
+func (plan *BuildPlan) Owns(outPath string, source string) bool {
+	return plan.Outputs[filepath.Clean(outPath)] == source
+}
+
+// Get sorted output paths
+func (plan *BuildPlan) Paths() []string {
+	paths := make([]string, 0, len(plan.Outputs))
+	for outPath := range plan.Outputs {
+		paths = append(paths, outPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Plan static files to copy, directories are expanded to files
+func (plan *BuildPlan) AddCopy(srcList []string) []CopyItem {
+	items := make([]CopyItem, 0)
+	for _, source := range srcList {
+		matches, err := filepath.Glob(filepath.Join(rootPath, source))
+		if err != nil {
+			BuildFatal(err.Error())
+		}
+		for _, srcPath := range matches {
+			file, err := os.Stat(srcPath)
+			if err != nil {
+				BuildFatal("Not exist: " + srcPath)
+			}
+			if !file.IsDir() {
+				if plan.Add(file.Name(), srcPath) {
+					items = append(items, CopyItem{srcPath, filepath.Join(publicPath, file.Name())})
+				}
+				continue
+			}
+			baseDir := filepath.Dir(srcPath)
+			symwalk.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+				if info == nil || info.IsDir() {
+					return nil
+				}
+				relPath, _ := filepath.Rel(baseDir, path)
+				if plan.Add(relPath, path) {
+					items = append(items, CopyItem{path, filepath.Join(publicPath, relPath)})
+				}
+				return nil
+			})
+		}
+	}
+	return items
+}
+
+// Plan html files in source folder rendered as pages
+func (plan *BuildPlan) AddSourcePages() []string {
+	pages := make([]string, 0)
+	files, _ := filepath.Glob(filepath.Join(sourcePath, "*.html"))
+	for _, path := range files {
+		fileExt := strings.ToLower(filepath.Ext(path))
+		baseName := filepath.Base(path)
+		if fileExt == ".html" && !strings.HasPrefix(baseName, "_") {
+			relPath, _ := filepath.Rel(sourcePath, path)
+			if plan.Add(relPath, path) {
+				pages = append(pages, path)
+			}
+		}
+	}
+	return pages
+}
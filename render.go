@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/feeds"
@@ -21,44 +23,101 @@ type RenderArticle struct {
 	Prev *Article
 }
 
+// Data of html page in source folder
+type SourcePage struct {
+	*GlobalConfig
+	PageSeo *PageSeo
+}
+
+// Partial template defined by _name.html of theme
+type PartialTpl struct {
+	Name    string
+	Path    string
+	Content string
+}
+
+// Article templates compiled on first use
+type ArticleTpls struct {
+	sync.Mutex
+	partials []PartialTpl
+	funcCxt  FuncContext
+	tpls     map[string]template.Template
+}
+
+var articleTpls *ArticleTpls
+
+func NewArticleTpls(partials []PartialTpl, funcCxt FuncContext) *ArticleTpls {
+	return &ArticleTpls{
+		partials: partials,
+		funcCxt:  funcCxt,
+		tpls:     make(map[string]template.Template),
+	}
+}
+
+// Get compiled template of path
+func (articleTpls *ArticleTpls) Get(tplPath string) template.Template {
+	articleTpls.Lock()
+	defer articleTpls.Unlock()
+	if tpl, ok := articleTpls.tpls[tplPath]; ok {
+		return tpl
+	}
+	name := strings.TrimSuffix(filepath.Base(tplPath), filepath.Ext(tplPath))
+	tpl := CompileTpl(tplPath, articleTpls.partials, name, articleTpls.funcCxt)
+	articleTpls.tpls[tplPath] = tpl
+	return tpl
+}
+
 // Compile html template
-func CompileTpl(tplPath string, partialTpl string, name string, funcContext FuncContext) template.Template {
+func CompileTpl(tplPath string, partials []PartialTpl, name string, funcContext FuncContext) template.Template {
 	// Read template data from file
 	html, err := os.ReadFile(tplPath)
 	if err != nil {
-		Fatal(err.Error())
+		BuildFatal(err.Error())
 	}
-	// Append partial template
-	htmlStr := string(html) + partialTpl
+	templatePaths.Set(name, tplPath)
 	// Generate html content
-	tpl, err := template.New(name).Funcs(funcContext.FuncMap()).Parse(htmlStr)
+	tpl, err := template.New(name).Funcs(funcContext.FuncMap()).Parse(string(html))
 	if err != nil {
-		Fatal(err.Error())
+		BuildFatal(NewTemplateError(err))
+	}
+	// Append partial template
+	for _, partial := range partials {
+		templatePaths.Set(partial.Name, partial.Path)
+		if _, err := tpl.New(partial.Name).Parse(partial.Content); err != nil {
+			BuildFatal(NewTemplateError(err))
+		}
 	}
 	return *tpl
 }
 
 // Render html file by data
 func RenderPage(tpl template.Template, tplData interface{}, outPath string) {
-	// Create file
-	outFile, err := os.Create(outPath)
-	if err != nil {
-		Fatal(err.Error())
-	}
-	defer func() {
-		outFile.Close()
-	}()
 	defer wg.Done()
+	defer RecoverBuildError()
 	// Template render
-	err = tpl.Execute(outFile, tplData)
+	var out bytes.Buffer
+	err := tpl.Execute(&out, tplData)
+	if err != nil {
+		BuildFatal(NewTemplateError(err))
+	}
+	data := out.Bytes()
+	if globalConfig.Develop && strings.ToLower(filepath.Ext(outPath)) == ".html" {
+		data = InjectLiveReload(data)
+	}
+	if ShouldMinify(outPath) {
+		data = MinifyBytes(outPath, data)
+	}
+	// Create file
+	err = os.WriteFile(outPath, data, 0644)
 	if err != nil {
-		Fatal(err.Error())
+		BuildFatal(err.Error())
 	}
 }
 
 // Generate all article page
-func RenderArticles(tpl template.Template, articles Collections) {
+func RenderArticles(articles Collections) {
 	defer wg.Done()
+	defer RecoverBuildError()
 	articleCount := len(articles)
 	for i := range articles {
 		currentArticle := articles[i].(Article)
@@ -86,15 +145,16 @@ func RenderArticles(tpl template.Template, articles Collections) {
 				}
 			}
 		}
-		outPath := filepath.Join(publicPath, currentArticle.Link)
+		outPath := filepath.Join(publicPath, LinkPath(currentArticle.Link))
 		wg.Add(1)
-		go RenderPage(tpl, renderArticle, outPath)
+		go RenderPage(articleTpls.Get(currentArticle.tplPath), renderArticle, outPath)
 	}
 }
 
 // Generate rss page
 func GenerateRSS(articles Collections) {
 	defer wg.Done()
+	defer RecoverBuildError()
 	var feedArticles Collections
 	if len(articles) < globalConfig.Site.Limit {
 		feedArticles = articles
@@ -124,10 +184,10 @@ func GenerateRSS(articles Collections) {
 		if atom, err := feed.ToAtom(); err == nil {
 			err := os.WriteFile(filepath.Join(publicPath, "atom.xml"), []byte(atom), 0644)
 			if err != nil {
-				Fatal(err.Error())
+				BuildFatal(err.Error())
 			}
 		} else {
-			Fatal(err.Error())
+			BuildFatal(err.Error())
 		}
 	}
 }
@@ -135,6 +195,7 @@ func GenerateRSS(articles Collections) {
 // Generate sitemap page
 func GenerateSitemap(articles Collections) {
 	defer wg.Done()
+	defer RecoverBuildError()
 
 	if globalConfig.Site.Url != "" {
 		sm := sitemap.New()
@@ -167,41 +228,56 @@ func GenerateSitemap(articles Collections) {
 		sm.WriteTo(&sitemap)
 		err := os.WriteFile(filepath.Join(publicPath, "sitemap.xml"), sitemap.Bytes(), 0644)
 		if err != nil {
-			Fatal(err.Error())
+			BuildFatal(err.Error())
 		}
 	}
 }
 
+// Get page count of article list
+func ListPageCount(total int) int {
+	limit := globalConfig.Site.Limit
+	page := total / limit
+	if total%limit != 0 {
+		page++
+	}
+	if total < limit {
+		page = 1
+	}
+	return page
+}
+
+// Get output path of article list page, index starts from 0
+func ListPagePath(rootPath string, index int) string {
+	if index == 0 {
+		return filepath.Join(rootPath, "index.html")
+	}
+	return filepath.Join(rootPath, "page"+strconv.Itoa(index+1)+".html")
+}
+
 // Generate article list page
 func RenderArticleList(rootPath string, articles Collections, tagName string) {
 	defer wg.Done()
+	defer RecoverBuildError()
 	// Create path
 	pagePath := filepath.Join(publicPath, rootPath)
 	os.MkdirAll(pagePath, 0777)
 	// Split page
 	limit := globalConfig.Site.Limit
 	total := len(articles)
-	page := total / limit
+	page := ListPageCount(total)
 	rest := total % limit
-	if rest != 0 {
-		page++
-	}
-	if total < limit {
-		page = 1
-	}
 	for i := 0; i < page; i++ {
-		var prev = filepath.Join(rootPath, "page"+strconv.Itoa(i)+".html")
-		var next = filepath.Join(rootPath, "page"+strconv.Itoa(i+2)+".html")
-		outPath := filepath.Join(pagePath, "index.html")
-		if i != 0 {
-			fileName := "page" + strconv.Itoa(i+1) + ".html"
-			outPath = filepath.Join(pagePath, fileName)
-		} else {
-			prev = ""
+		relPath := ListPagePath(rootPath, i)
+		// Skip the page if its output is taken by other source
+		if !buildPlan.Owns(relPath, listTplPath) {
+			continue
 		}
-		if i == 1 {
-			prev = filepath.Join(rootPath, "index.html")
+		var prev string
+		if i != 0 {
+			prev = ListPagePath(rootPath, i-1)
 		}
+		next := ListPagePath(rootPath, i+1)
+		outPath := filepath.Join(publicPath, relPath)
 		first := i * limit
 		count := first + limit
 		if i == page-1 {
@@ -220,15 +296,17 @@ func RenderArticleList(rootPath string, articles Collections, tagName string) {
 			"Next":     template.URL(filepath.ToSlash(next)),
 			"TagName":  tagName,
 			"TagCount": len(articles),
+			"PageSeo":  SiteSeo(relPath, tagName),
 		}
 		wg.Add(1)
-		go RenderPage(pageTpl, data, outPath)
+		go RenderPage(listTpl, data, outPath)
 	}
 }
 
 // Generate article list JSON
 func GenerateJSON(articles Collections) {
 	defer wg.Done()
+	defer RecoverBuildError()
 	datas := make([]map[string]interface{}, 0)
 	for i := range articles {
 		article := articles[i].(Article)
@@ -238,6 +316,9 @@ func GenerateJSON(articles Collections) {
 			"preview": string(article.Preview),
 			"link":    article.Link,
 			"cover":   article.Cover,
+			"summary": article.Summary,
+			"words":   article.WordCount,
+			"reading": article.ReadingTime,
 		}
 		datas = append(datas, data)
 	}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"embed"
+	"encoding/hex"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Blog skeleton and default theme
+//
+//go:embed template/config.yml template/source
+//go:embed template/theme/*.html template/theme/*.yml template/theme/*.png template/theme/*.txt
+//go:embed template/theme/*.js template/theme/*.json template/theme/bundle template/theme/source
+//go:embed template/theme/shortcodes
+var skeletonFS embed.FS
+
+const (
+	SKELETON_ROOT = "template"
+	BUILTIN_THEME = "template/theme"
+)
+
+// Write files of embedded folder to dest
+func extractEmbed(root string, dest string) error {
+	return fs.WalkDir(skeletonFS, root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath := strings.TrimPrefix(strings.TrimPrefix(filePath, root), "/")
+		target := filepath.Join(dest, filepath.FromSlash(relPath))
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := skeletonFS.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
+
+// Hash embedded theme to name its extracted folder
+func builtinThemeHash() string {
+	hash := fnv.New64a()
+	fs.WalkDir(skeletonFS, BUILTIN_THEME, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		data, _ := skeletonFS.ReadFile(filePath)
+		hash.Write([]byte(filePath))
+		hash.Write(data)
+		return nil
+	})
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Extract built-in theme to user cache folder once and return its path
+func BuiltinThemePath() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	themeDir := filepath.Join(cacheDir, "ink", "theme-"+builtinThemeHash())
+	if Exists(themeDir) {
+		return themeDir
+	}
+	if err := os.MkdirAll(filepath.Dir(themeDir), 0755); err != nil {
+		BuildFatal(err.Error())
+	}
+	// Extract to temporary folder first, so a broken extraction is never used
+	tmpDir, err := os.MkdirTemp(filepath.Dir(themeDir), "tmp-")
+	if err != nil {
+		BuildFatal(err.Error())
+	}
+	if err := extractEmbed(BUILTIN_THEME, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		BuildFatal(err.Error())
+	}
+	if err := os.Rename(tmpDir, themeDir); err != nil {
+		// Extracted by another process
+		os.RemoveAll(tmpDir)
+		if !Exists(themeDir) {
+			BuildFatal(err.Error())
+		}
+	}
+	return themeDir
+}
+
+// Get languages supported by built-in theme
+func builtinLanguages() []string {
+	data, err := skeletonFS.ReadFile(path.Join(BUILTIN_THEME, "config.yml"))
+	if err != nil {
+		return nil
+	}
+	var config ThemeConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil
+	}
+	langSet := make(map[string]bool)
+	for _, langItem := range config.Lang {
+		for lang := range langItem {
+			langSet[lang] = true
+		}
+	}
+	langs := make([]string, 0, len(langSet))
+	for lang := range langSet {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Create a new blog in folder
+func Init(c *cli.Context) {
+	dir := "."
+	if c.Args().Len() > 0 {
+		dir = c.Args().First()
+	}
+	configPath := filepath.Join(dir, "config.yml")
+	if Exists(configPath) {
+		Fatal("Blog already exists: " + configPath)
+	}
+	lang := c.String("lang")
+	langs := builtinLanguages()
+	supported := false
+	for _, item := range langs {
+		supported = supported || item == lang
+	}
+	if !supported {
+		Fatal("Unsupported language " + lang + ", available: " + strings.Join(langs, ", "))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		Fatal(err.Error())
+	}
+	if Exists(filepath.Join(dir, "source")) {
+		Log("Keep existing source folder")
+	} else if err := extractEmbed(path.Join(SKELETON_ROOT, "source"), filepath.Join(dir, "source")); err != nil {
+		Fatal(err.Error())
+	}
+	for _, folder := range []string{"post", "page"} {
+		os.MkdirAll(filepath.Join(dir, "source", folder), 0755)
+	}
+	data, err := skeletonFS.ReadFile(path.Join(SKELETON_ROOT, "config.yml"))
+	if err != nil {
+		Fatal(err.Error())
+	}
+	config := strings.Replace(string(data), "    lang: zh-cn\n", "    lang: "+lang+"\n", 1)
+	if c.Bool("vendor") {
+		if err := extractEmbed(BUILTIN_THEME, filepath.Join(dir, "theme")); err != nil {
+			Fatal(err.Error())
+		}
+	} else {
+		config = strings.Replace(config, "    theme: theme\n", "    # Built-in theme is used when theme is not set\n    # theme: theme\n", 1)
+	}
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		Fatal(err.Error())
+	}
+	Log("Created blog in " + dir + ", run 'ink preview " + dir + "' to preview")
+}
+
+// Find blog root containing config.yml from current folder upwards
+func FindRootPath() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	for {
+		if Exists(filepath.Join(dir, "config.yml")) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "."
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/facebookgo/symwalk"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Problems of config file, warnings do not stop building
+type ConfigIssues struct {
+	Errors   []*BuildError
+	Warnings []*BuildError
+}
+
+// Allowed values of config keys
+var configEnums = map[string][]string{
+	"comments.provider": {"disqus", "giscus", "utterances", "gitalk", "waline", "twikoo", "iframe"},
+}
+
+// Config files with their schema
+var configSchemas = map[string]interface{}{
+	"config":  GlobalConfig{},
+	"theme":   ThemeConfig{},
+	"article": ArticleConfig{},
+}
+
+// Get yaml key of struct field, false if it is not decoded
+func yamlKey(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, true
+}
+
+// Get fields of struct by yaml key
+func yamlFields(structType reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if key, ok := yamlKey(field); ok {
+			fields[key] = field
+		}
+	}
+	return fields
+}
+
+// Edit distance of two keys, swapping adjacent letters counts as one edit
+func editDistance(a string, b string) int {
+	dist := make([][]int, len(a)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(b)+1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			dist[i][j] = minInt(dist[i-1][j]+1, dist[i][j-1]+1, dist[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				dist[i][j] = minInt(dist[i][j], dist[i-2][j-2]+1)
+			}
+		}
+	}
+	return dist[len(a)][len(b)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
+
+// Find the closest known key of misspelled key
+func suggestKey(key string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", len(key)/3+1
+	for name := range fields {
+		distance := editDistance(strings.ToLower(key), name)
+		if distance < bestDistance || (distance == bestDistance && best != "" && name < best) {
+			best, bestDistance = name, distance
+		}
+	}
+	return best
+}
+
+// Name of yaml node kind used in messages
+func yamlKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "list"
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	}
+	return "string"
+}
+
+func joinKey(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// Check yaml node against type of config
+func (issues *ConfigIssues) checkNode(file string, lineOffset int, node *yaml.Node, valueType reflect.Type, key string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.ShortTag() == "!!null" {
+		return
+	}
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+	mismatch := func(expected string) {
+		issues.Errors = append(issues.Errors, &BuildError{
+			File:    file,
+			Line:    node.Line + lineOffset,
+			Message: fmt.Sprintf("%s should be %s, not %s", key, expected, yamlKind(node)),
+		})
+	}
+	switch valueType.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			mismatch("a mapping")
+			return
+		}
+		fields := yamlFields(valueType)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			// Merge key of yaml anchors
+			if keyNode.Value == "<<" {
+				issues.checkNode(file, lineOffset, valueNode, valueType, key)
+				continue
+			}
+			field, ok := fields[keyNode.Value]
+			if !ok {
+				message := fmt.Sprintf("unknown key %s", joinKey(key, keyNode.Value))
+				if suggestion := suggestKey(keyNode.Value, fields); suggestion != "" {
+					message += fmt.Sprintf(", did you mean %s?", joinKey(key, suggestion))
+				}
+				issues.Warnings = append(issues.Warnings, &BuildError{File: file, Line: keyNode.Line + lineOffset, Message: message})
+				continue
+			}
+			issues.checkNode(file, lineOffset, valueNode, field.Type, joinKey(key, keyNode.Value))
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			mismatch("a mapping")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			issues.checkNode(file, lineOffset, node.Content[i+1], valueType.Elem(), joinKey(key, node.Content[i].Value))
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			mismatch("a list")
+			return
+		}
+		for i, item := range node.Content {
+			issues.checkNode(file, lineOffset, item, valueType.Elem(), fmt.Sprintf("%s[%d]", key, i))
+		}
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!bool" {
+			mismatch("true or false")
+		}
+	case reflect.Int, reflect.Int64:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!int" {
+			mismatch("an integer")
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			mismatch("a string")
+			return
+		}
+		if values, ok := configEnums[key]; ok && !containsString(values, strings.ToLower(node.Value)) {
+			issues.Errors = append(issues.Errors, &BuildError{
+				File:    file,
+				Line:    node.Line + lineOffset,
+				Message: fmt.Sprintf("unknown %s %s, available: %s", key, node.Value, strings.Join(values, ", ")),
+			})
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Check yaml data against config struct, lineOffset is the line number before yaml content
+func CheckYaml(file string, lineOffset int, data []byte, config interface{}) *ConfigIssues {
+	issues := &ConfigIssues{}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		issues.Errors = append(issues.Errors, NewYamlError(file, lineOffset, err))
+		return issues
+	}
+	if len(root.Content) == 0 {
+		return issues
+	}
+	issues.checkNode(file, lineOffset, root.Content[0], reflect.TypeOf(config), "")
+	return issues
+}
+
+// Print warnings and stop at the first error
+func (issues *ConfigIssues) Report() {
+	for _, warning := range issues.Warnings {
+		Warn(warning.Error())
+	}
+	if len(issues.Errors) > 0 {
+		BuildFatal(issues.Errors[0])
+	}
+}
+
+// Line of key path in yaml data, 0 if not found
+func yamlKeyLine(data []byte, path ...string) int {
+	var root yaml.Node
+	if yaml.Unmarshal(data, &root) != nil || len(root.Content) == 0 {
+		return 0
+	}
+	node, line := root.Content[0], 0
+	for _, key := range path {
+		found := false
+		for i := 0; node.Kind == yaml.MappingNode && i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				node, line, found = node.Content[i+1], node.Content[i].Line, true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return line
+}
+
+// Get JSON Schema of config type
+func JsonSchema(valueType reflect.Type, key string) map[string]interface{} {
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+	switch valueType.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for name, field := range yamlFields(valueType) {
+			properties[name] = JsonSchema(field.Type, joinKey(key, name))
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": JsonSchema(valueType.Elem(), key),
+		}
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": JsonSchema(valueType.Elem(), key),
+		}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.String:
+		if values, ok := configEnums[key]; ok {
+			return map[string]interface{}{"type": "string", "enum": values}
+		}
+		// Scalars are decoded as strings
+		return map[string]interface{}{"type": []string{"string", "number", "boolean"}}
+	}
+	return map[string]interface{}{}
+}
+
+// Check config, theme configs and front matter of articles
+func CheckSite(root string) *ConfigIssues {
+	issues := &ConfigIssues{}
+	merge := func(other *ConfigIssues) {
+		issues.Errors = append(issues.Errors, other.Errors...)
+		issues.Warnings = append(issues.Warnings, other.Warnings...)
+	}
+	configPath := filepath.Join(root, "config.yml")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		issues.Errors = append(issues.Errors, &BuildError{File: configPath, Message: err.Error()})
+		return issues
+	}
+	merge(CheckYaml(configPath, 0, data, GlobalConfig{}))
+	var config GlobalConfig
+	if len(issues.Errors) > 0 || yaml.Unmarshal(data, &config) != nil {
+		return issues
+	}
+	if err := ValidateComments(&config); err != nil {
+		issues.Errors = append(issues.Errors, &BuildError{File: configPath, Line: yamlKeyLine(data, "comments"), Message: err.Error()})
+	}
+	// Theme chain
+	themeDir := filepath.Join(root, config.Site.Theme)
+	if config.Site.Theme == "" {
+		themeDir = BuiltinThemePath()
+	}
+	for dir, visited := themeDir, make(map[string]bool); dir != "" && !visited[dir]; {
+		visited[dir] = true
+		if !Exists(dir) {
+			issues.Errors = append(issues.Errors, &BuildError{File: configPath, Line: yamlKeyLine(data, "site", "theme"), Message: "Theme not found: " + dir})
+			break
+		}
+		themePath := filepath.Join(dir, "config.yml")
+		themeData, err := os.ReadFile(themePath)
+		if err != nil {
+			break
+		}
+		themeIssues := CheckYaml(themePath, 0, themeData, ThemeConfig{})
+		merge(themeIssues)
+		var theme ThemeConfig
+		if len(themeIssues.Errors) > 0 || yaml.Unmarshal(themeData, &theme) != nil || theme.Extends == "" {
+			break
+		}
+		dir = filepath.Join(dir, theme.Extends)
+	}
+	// Front matter of articles
+	symwalk.Walk(filepath.Join(root, "source"), func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || strings.ToLower(filepath.Ext(path)) != ".md" {
+			return nil
+		}
+		markdown, err := os.ReadFile(path)
+		if err != nil {
+			issues.Errors = append(issues.Errors, &BuildError{File: path, Message: err.Error()})
+			return nil
+		}
+		front := []byte(strings.SplitN(string(markdown), CONFIG_SPLIT, 2)[0])
+		articleIssues := CheckYaml(path, 0, front, ArticleConfig{})
+		merge(articleIssues)
+		var article ArticleConfig
+		if len(articleIssues.Errors) > 0 || yaml.Unmarshal(front, &article) != nil {
+			return nil
+		}
+		for field, value := range map[string]string{"date": article.Date, "update": article.Update} {
+			if _, err := ParseDateString(value); value != "" && err != nil {
+				issues.Errors = append(issues.Errors, &BuildError{File: path, Line: yamlKeyLine(front, field), Message: err.Error()})
+			}
+		}
+		if _, ok := config.Authors[article.Author]; article.Author != "" && !ok {
+			issues.Warnings = append(issues.Warnings, &BuildError{File: path, Line: yamlKeyLine(front, "author"), Message: "author " + article.Author + " is not defined in config.yml"})
+		}
+		return nil
+	})
+	return issues
+}
+
+// Check config files without building
+func ConfigCheck(c *cli.Context) {
+	root := c.String("root")
+	if root == "" {
+		root = FindRootPath()
+	}
+	issues := CheckSite(root)
+	for _, warning := range issues.Warnings {
+		Warn(warning.Error())
+	}
+	for _, err := range issues.Errors {
+		Error(err.Error())
+	}
+	if len(issues.Errors) > 0 {
+		Fatal(fmt.Sprintf("Found %d errors and %d warnings", len(issues.Errors), len(issues.Warnings)))
+	}
+	Log(fmt.Sprintf("Config is valid, %d warnings", len(issues.Warnings)))
+}
+
+// Print JSON Schema of config.yml, theme config.yml or front matter of articles
+func ConfigSchema(c *cli.Context) {
+	kind := c.Args().First()
+	if kind == "" {
+		kind = "config"
+	}
+	config, ok := configSchemas[kind]
+	if !ok {
+		names := make([]string, 0, len(configSchemas))
+		for name := range configSchemas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		Fatal("Unknown schema: " + kind + ", available: " + strings.Join(names, ", "))
+	}
+	schema := JsonSchema(reflect.TypeOf(config), "")
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "InkPaper " + kind
+	data, _ := json.MarshalIndent(schema, "", INDENT)
+	fmt.Println(string(data))
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SEO defaults of site or overrides of article
+type SeoConfig struct {
+	Title       string
+	Description string
+	// Image shown in link previews
+	Image string
+	// Twitter account, such as @inkpaper
+	Twitter string
+	// Canonical url of article published elsewhere first
+	Canonical string
+}
+
+// SEO data of page rendered by seo function
+type PageSeo struct {
+	Title       string
+	Description string
+	// Canonical url
+	Url      string
+	Image    string
+	SiteName string
+	Twitter  string
+	// article or website
+	Type      string
+	Published time.Time
+	Modified  time.Time
+	Author    *AuthorConfig
+	Tags      []string
+	// Page is not indexed, such as 404.html
+	NoIndex bool
+}
+
+const SEO_TEMPLATE = `{{if .NoIndex}}<meta name="robots" content="noindex">{{else}}<meta name="robots" content="index,follow">
+<link rel="canonical" href="{{.Url}}">{{end}}
+<meta name="description" content="{{.Description}}">
+<meta property="og:type" content="{{.Type}}">
+{{- if not .NoIndex}}
+<meta property="og:url" content="{{.Url}}">
+{{- end}}
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:site_name" content="{{.SiteName}}">
+{{- if .Image}}
+<meta property="og:image" content="{{.Image}}">
+{{- end}}
+{{- if eq .Type "article"}}
+{{- if not .Published.IsZero}}
+<meta property="article:published_time" content="{{.Published.Format "2006-01-02T15:04:05Z07:00"}}">
+{{- end}}
+{{- if not .Modified.IsZero}}
+<meta property="article:modified_time" content="{{.Modified.Format "2006-01-02T15:04:05Z07:00"}}">
+{{- end}}
+{{- with .Author}}
+<meta property="article:author" content="{{.Name}}">
+{{- end}}
+{{- range .Tags}}
+<meta property="article:tag" content="{{.}}">
+{{- end}}
+{{- end}}
+<meta name="twitter:card" content="{{if .Image}}summary_large_image{{else}}summary{{end}}">
+{{- if .Twitter}}
+<meta name="twitter:site" content="{{.Twitter}}">
+{{- end}}
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+{{- if .Image}}
+<meta name="twitter:image" content="{{.Image}}">
+{{- end}}
+<script type="application/ld+json">{{.JsonLd}}</script>`
+
+var seoTpl = template.Must(template.New("seo").Parse(SEO_TEMPLATE))
+
+// Make link of site absolute by site url
+func AbsoluteUrl(link string) string {
+	site := globalConfig.Site
+	if link == "" || strings.HasPrefix(link, "//") || strings.Contains(link, "://") || strings.HasPrefix(link, "data:") {
+		return link
+	}
+	if strings.HasPrefix(link, "/") {
+		// Absolute path already contains site root
+		if siteUrl, err := url.Parse(site.Url); err == nil && siteUrl.Host != "" {
+			return siteUrl.Scheme + "://" + siteUrl.Host + link
+		}
+		return site.Url + link
+	}
+	return site.Url + "/" + link
+}
+
+// Get site level SEO data of page at link
+func SiteSeo(link string, title string) *PageSeo {
+	site := globalConfig.Site
+	seo := &PageSeo{
+		Title:       site.Seo.Title,
+		Description: site.Seo.Description,
+		Url:         site.Url + "/" + strings.TrimSuffix(filepath.ToSlash(link), "index.html"),
+		Image:       AbsoluteUrl(site.Seo.Image),
+		SiteName:    site.Title,
+		Twitter:     site.Seo.Twitter,
+		Type:        "website",
+	}
+	if seo.Title == "" {
+		seo.Title = site.Title
+	}
+	if title != "" {
+		seo.Title = title + " - " + site.Title
+	}
+	if seo.Description == "" {
+		seo.Description = site.Subtitle
+	}
+	if seo.Image == "" {
+		seo.Image = AbsoluteUrl(site.Logo)
+	}
+	return seo
+}
+
+// Get SEO data of not found page, it has no canonical url
+func NotFoundSeo() *PageSeo {
+	seo := SiteSeo("", "")
+	seo.NoIndex = true
+	return seo
+}
+
+// Get SEO data of article, front matter overrides site defaults
+func ArticleSeo(article *Article, config *ArticleConfig) *PageSeo {
+	seo := SiteSeo(article.Link, "")
+	seo.Type = "article"
+	seo.Title = article.Title
+	if article.Summary != "" {
+		seo.Description = article.Summary
+	} else if article.Subtitle != "" {
+		seo.Description = article.Subtitle
+	}
+	for _, image := range []string{article.Cover, article.Image} {
+		if image != "" {
+			seo.Image = AbsoluteUrl(image)
+			break
+		}
+	}
+	seo.Published = article.Time
+	seo.Modified = article.MTime
+	if article.Author.Name != "" {
+		author := article.Author
+		seo.Author = &author
+	}
+	seo.Tags = article.Tags
+	overrides := config.Seo
+	if overrides.Title != "" {
+		seo.Title = overrides.Title
+	}
+	if overrides.Description != "" {
+		seo.Description = overrides.Description
+	}
+	if overrides.Image != "" {
+		seo.Image = AbsoluteUrl(overrides.Image)
+	}
+	if overrides.Twitter != "" {
+		seo.Twitter = overrides.Twitter
+	}
+	if overrides.Canonical != "" {
+		seo.Url = overrides.Canonical
+	}
+	return seo
+}
+
+// Get JSON-LD structured data of page
+func (seo *PageSeo) JsonLd() template.JS {
+	data := map[string]interface{}{
+		"@context":    "https://schema.org",
+		"@type":       "WebSite",
+		"name":        seo.Title,
+		"url":         seo.Url,
+		"description": seo.Description,
+	}
+	if seo.Type == "article" {
+		data["@type"] = "BlogPosting"
+		delete(data, "name")
+		data["headline"] = seo.Title
+		data["mainEntityOfPage"] = seo.Url
+		publisher := map[string]interface{}{
+			"@type": "Organization",
+			"name":  seo.SiteName,
+		}
+		if logo := AbsoluteUrl(globalConfig.Site.Logo); logo != "" {
+			publisher["logo"] = map[string]interface{}{"@type": "ImageObject", "url": logo}
+		}
+		data["publisher"] = publisher
+		if !seo.Published.IsZero() {
+			data["datePublished"] = seo.Published.Format(time.RFC3339)
+		}
+		if !seo.Modified.IsZero() {
+			data["dateModified"] = seo.Modified.Format(time.RFC3339)
+		}
+		if seo.Author != nil {
+			author := map[string]interface{}{
+				"@type": "Person",
+				"name":  seo.Author.Name,
+			}
+			if seo.Author.Intro != "" {
+				author["description"] = seo.Author.Intro
+			}
+			if seo.Author.Avatar != "" {
+				author["image"] = AbsoluteUrl(seo.Author.Avatar)
+			}
+			data["author"] = author
+		}
+		if len(seo.Tags) > 0 {
+			data["keywords"] = strings.Join(seo.Tags, ", ")
+		}
+	}
+	if seo.Image != "" {
+		data["image"] = seo.Image
+	}
+	// Marshal escapes <, > and & so that script can not be closed
+	jsonData, _ := json.Marshal(data)
+	return template.JS(jsonData)
+}
+
+// Render SEO meta tags of page data, site defaults are used for other data
+func (ctx FuncContext) Seo(data interface{}) template.HTML {
+	var seo *PageSeo
+	switch page := data.(type) {
+	case *PageSeo:
+		seo = page
+	case RenderArticle:
+		seo = page.PageSeo
+	case Article:
+		seo = page.PageSeo
+	case SourcePage:
+		seo = page.PageSeo
+	case map[string]interface{}:
+		seo, _ = page["PageSeo"].(*PageSeo)
+	}
+	if seo == nil {
+		seo = SiteSeo("", "")
+	}
+	var out bytes.Buffer
+	if err := seoTpl.Execute(&out, seo); err != nil {
+		BuildFatal(NewTemplateError(err))
+	}
+	return template.HTML(out.String())
+}
@@ -1,18 +1,31 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/InkProject/ink.go"
 	"github.com/facebookgo/symwalk"
 	"github.com/fsnotify/fsnotify"
-	"github.com/gorilla/websocket"
 )
 
 var watcher *fsnotify.Watcher
-var conn *websocket.Conn
 
 func buildWatchList() (files []string, dirs []string) {
 	dirs = []string{
@@ -20,22 +33,27 @@ func buildWatchList() (files []string, dirs []string) {
 	}
 	files = []string{
 		filepath.Join(rootPath, "config.yml"),
-		filepath.Join(themePath),
 	}
 
-	// Add files and directories defined in theme's config.yml to watcher
-	for _, themeCopiedPath := range themeConfig.Copy {
-		if themeCopiedPath != "" {
-			fullPath := filepath.Join(themePath, themeCopiedPath)
-			s, err := os.Stat(fullPath)
-			if s == nil || err != nil {
-				continue
-			}
+	// Add template directories and files defined in theme's config.yml to watcher
+	for _, dir := range TemplateDirs(rootPath, themeConfig) {
+		files = append(files, dir)
+		if shortcodesDir := filepath.Join(dir, SHORTCODES_DIR); Exists(shortcodesDir) {
+			dirs = append(dirs, shortcodesDir)
+		}
+		for _, themeCopiedPath := range themeConfig.Copy {
+			if themeCopiedPath != "" {
+				fullPath := filepath.Join(dir, themeCopiedPath)
+				s, err := os.Stat(fullPath)
+				if s == nil || err != nil {
+					continue
+				}
 
-			if s.IsDir() {
-				dirs = append(dirs, fullPath)
-			} else {
-				files = append(files, fullPath)
+				if s.IsDir() {
+					dirs = append(dirs, fullPath)
+				} else {
+					files = append(files, fullPath)
+				}
 			}
 		}
 	}
@@ -62,6 +80,44 @@ func configureWatcher(watcher *fsnotify.Watcher, files []string, dirs []string)
 	return nil
 }
 
+// Wait for no more events before rebuilding
+const WATCH_DEBOUNCE = 200 * time.Millisecond
+
+// Ignore editor swap and backup files
+func ignoreWatchEvent(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return true
+	}
+	baseName := filepath.Base(event.Name)
+	return strings.HasPrefix(baseName, ".") || strings.HasSuffix(baseName, "~") ||
+		strings.HasSuffix(baseName, ".swp") || strings.HasSuffix(baseName, ".tmp")
+}
+
+// Held while building, handlers rendering with build globals wait for it
+var buildLock sync.Mutex
+
+// Parse config and build again, errors are reported to browser instead of exiting
+func rebuild() error {
+	buildLock.Lock()
+	defer buildLock.Unlock()
+	err := CatchBuildError(func() {
+		config, theme := ParseGlobalConfig(filepath.Join(rootPath, "config.yml"), true)
+		if config == nil || theme == nil {
+			BuildFatal("Parse config.yml failed")
+		}
+		globalConfig, themeConfig = config, theme
+	})
+	if err == nil {
+		err = Build()
+	}
+	if err != nil {
+		liveHub.ReportError(err)
+		return err
+	}
+	liveHub.Broadcast(LiveMessage{Type: "change", Paths: SnapshotOutputs()})
+	return nil
+}
+
 func Watch() {
 	// Listen watched file change event
 	if watcher != nil {
@@ -69,31 +125,42 @@ func Watch() {
 	}
 	watcher, _ = fsnotify.NewWatcher()
 	files, dirs := buildWatchList()
+	SnapshotOutputs()
 	go func() {
+		var trigger <-chan time.Time
 		for {
 			select {
-			case event := <-watcher.Events:
-				if event.Op == fsnotify.Write {
-					// Handle when file change
-					Log(event.Name)
-					ParseGlobalConfigWrap(rootPath, true)
-
-					newFiles, newDirs := buildWatchList()
-					// If file list changed, reconfigure watcher
-					if !reflect.DeepEqual(files, newFiles) || !reflect.DeepEqual(dirs, newDirs) {
-						configureWatcher(watcher, newFiles, newDirs)
-						files = newFiles
-						dirs = newDirs
-					}
-
-					Build()
-					if conn != nil {
-						if err := conn.WriteMessage(websocket.TextMessage, []byte("change")); err != nil {
-							Warn(err.Error())
-						}
-					}
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if ignoreWatchEvent(event) {
+					continue
+				}
+				// Handle when file change
+				Log(event.Op.String() + " " + event.Name)
+				// Watch new directories
+				if event.Op&fsnotify.Create == fsnotify.Create && IsDir(event.Name) {
+					configureWatcher(watcher, nil, []string{event.Name})
+				}
+				trigger = time.After(WATCH_DEBOUNCE)
+			case <-trigger:
+				// Builds run one by one in this goroutine
+				trigger = nil
+				if rebuild() != nil {
+					continue
+				}
+				newFiles, newDirs := buildWatchList()
+				// If file list changed, reconfigure watcher
+				if !reflect.DeepEqual(files, newFiles) || !reflect.DeepEqual(dirs, newDirs) {
+					configureWatcher(watcher, newFiles, newDirs)
+					files = newFiles
+					dirs = newDirs
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
 				}
-			case err := <-watcher.Errors:
 				Warn(err.Error())
 			}
 		}
@@ -101,17 +168,189 @@ func Watch() {
 	configureWatcher(watcher, files, dirs)
 }
 
-func Websocket(ctx *ink.Context) {
-	var upgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
+// Cache-Control header by file extension
+var cacheControls = map[string]string{
+	".html":  "no-cache",
+	".xml":   "no-cache",
+	".json":  "no-cache",
+	".txt":   "no-cache",
+	".css":   "public, max-age=86400",
+	".js":    "public, max-age=86400",
+	".png":   "public, max-age=604800",
+	".jpg":   "public, max-age=604800",
+	".jpeg":  "public, max-age=604800",
+	".gif":   "public, max-age=604800",
+	".svg":   "public, max-age=604800",
+	".webp":  "public, max-age=604800",
+	".ico":   "public, max-age=604800",
+	".woff":  "public, max-age=604800",
+	".woff2": "public, max-age=604800",
+}
+
+const CACHE_CONTROL_IMMUTABLE = "public, max-age=31536000, immutable"
+
+// Match fingerprinted file name such as index.3f2a9c1d.js
+var fingerprintRegexp = regexp.MustCompile(`[.-]([0-9a-fA-F]{8,})\.[^.]+$`)
+
+// Only assets are fingerprinted, pages keep their urls
+var fingerprintExts = map[string]bool{
+	".css":   true,
+	".js":    true,
+	".png":   true,
+	".jpg":   true,
+	".jpeg":  true,
+	".gif":   true,
+	".svg":   true,
+	".webp":  true,
+	".ico":   true,
+	".woff":  true,
+	".woff2": true,
+	".ttf":   true,
+	".otf":   true,
+	".eot":   true,
+}
+
+// Check if file name has content hash, dates such as IMG-20200101.jpg are not hashes
+func isFingerprinted(filePath string) bool {
+	if !fingerprintExts[strings.ToLower(filepath.Ext(filePath))] {
+		return false
+	}
+	match := fingerprintRegexp.FindStringSubmatch(filepath.Base(filePath))
+	return match != nil && strings.Trim(match[1], "0123456789") != ""
+}
+
+// Get Cache-Control header of file
+func cacheControl(filePath string) string {
+	if globalConfig.Develop {
+		return "no-cache"
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if value, ok := globalConfig.Build.Cache[strings.TrimPrefix(ext, ".")]; ok {
+		return value
+	}
+	if isFingerprinted(filePath) {
+		return CACHE_CONTROL_IMMUTABLE
+	}
+	if value, ok := cacheControls[ext]; ok {
+		return value
+	}
+	return "public, max-age=3600"
+}
+
+// Serve file with caching headers, supports conditional and range requests
+func serveFile(ctx *ink.Context, filePath string, info os.FileInfo, status int) {
+	servePath, serveInfo, encoding := findCompressed(ctx.Req.Header.Get("Accept-Encoding"), filePath, info)
+	file, err := os.Open(servePath)
+	if err != nil {
+		http.Error(ctx.Res, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	if c, err := upgrader.Upgrade(ctx.Res, ctx.Req, nil); err != nil {
-		Warn(err)
-	} else {
-		conn = c
+	defer file.Close()
+	header := ctx.Header()
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
 	}
-	ctx.Stop()
+	if encoding != "" || Exists(filePath+".gz") || Exists(filePath+".br") {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if status != http.StatusOK {
+		// Error page is neither cached nor partially served
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Content-Type", mime.TypeByExtension(filepath.Ext(filePath)))
+		ctx.Res.WriteHeader(status)
+		io.Copy(ctx.Res, file)
+		return
+	}
+	header.Set("Cache-Control", cacheControl(filePath))
+	header.Set("ETag", fmt.Sprintf(`"%x-%x%s"`, serveInfo.ModTime().UnixNano(), serveInfo.Size(), encoding))
+	// Content type is detected by name of the original file
+	http.ServeContent(ctx.Res, ctx.Req, filePath, serveInfo.ModTime(), file)
+}
+
+// Serve static files in public folder under site root
+func Static(root string) func(ctx *ink.Context) {
+	return func(ctx *ink.Context) {
+		defer ctx.Stop()
+		reqPath := ctx.Req.URL.Path
+		siteRoot := globalConfig.Site.Root
+		if siteRoot != "" {
+			if reqPath == "/" {
+				http.Redirect(ctx.Res, ctx.Req, siteRoot+"/", http.StatusFound)
+				return
+			}
+			if reqPath != siteRoot && !strings.HasPrefix(reqPath, siteRoot+"/") {
+				NotFound(ctx, root)
+				return
+			}
+			reqPath = strings.TrimPrefix(reqPath, siteRoot)
+		}
+		if ServeShare(ctx, reqPath) {
+			return
+		}
+		filePath := filepath.Join(root, filepath.FromSlash(path.Clean("/"+reqPath)))
+		info, err := os.Stat(filePath)
+		if err == nil && info.IsDir() {
+			filePath = filepath.Join(filePath, "index.html")
+			info, err = os.Stat(filePath)
+		}
+		if err != nil || info.IsDir() {
+			NotFound(ctx, root)
+			return
+		}
+		serveFile(ctx, filePath, info, http.StatusOK)
+	}
+}
+
+// Serve 404.html of public folder if exists
+func NotFound(ctx *ink.Context, root string) {
+	notFoundPath := filepath.Join(root, "404.html")
+	if info, err := os.Stat(notFoundPath); err == nil && !info.IsDir() {
+		serveFile(ctx, notFoundPath, info, http.StatusNotFound)
+		return
+	}
+	http.NotFound(ctx.Res, ctx.Req)
+}
+
+// Response writer records status and size for access log
+type logResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *logResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *logResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	size, err := w.ResponseWriter.Write(data)
+	w.size += size
+	return size, err
+}
+
+// Support websocket upgrade
+func (w *logResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Print access log of each request
+func AccessLog(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		logWriter := &logResponseWriter{ResponseWriter: w}
+		handler.ServeHTTP(logWriter, r)
+		Log(fmt.Sprintf("%s %s %s %d %d %v", r.RemoteAddr, r.Method, r.URL.RequestURI(),
+			logWriter.status, logWriter.size, time.Since(startTime)))
+	})
 }
 
 func Serve() {
@@ -132,10 +371,43 @@ func Serve() {
 	// go editorWeb.Listen(":2333")
 
 	previewWeb := ink.New()
-	previewWeb.Get("/live", Websocket)
-	previewWeb.Get("*", ink.Static(filepath.Join(rootPath, globalConfig.Build.Output)))
+	if globalConfig.Develop {
+		previewWeb.Get("/live", Websocket)
+	}
+	static := Static(filepath.Join(rootPath, globalConfig.Build.Output))
+	previewWeb.Get("*", static)
+	previewWeb.Head("*", static)
 
-	uri := "http://localhost:" + globalConfig.Build.Port + "/"
+	addr := net.JoinHostPort(globalConfig.Build.Host, globalConfig.Build.Port)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           AccessLog(&previewWeb),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Drain connections on shutdown
+	done := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		Log("Shutting down server")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			Warn(err.Error())
+		}
+		close(done)
+	}()
+
+	host := globalConfig.Build.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	uri := "http://" + net.JoinHostPort(host, globalConfig.Build.Port) + globalConfig.Site.Root + "/"
 	Log("Access " + uri + " to open preview")
-	previewWeb.Listen(":" + globalConfig.Build.Port)
+	serverStarted = true
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		Fatal(err.Error())
+	}
+	<-done
 }
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/InkProject/ink.go"
+	"github.com/urfave/cli/v2"
+)
+
+// Signed content of share token
+type ShareClaims struct {
+	// Markdown path relative to source folder
+	File    string `json:"f"`
+	Expires int64  `json:"e"`
+}
+
+const (
+	SHARE_PREFIX      = "/preview/"
+	SHARE_DEFAULT_TTL = 48 * time.Hour
+	SHARE_SECRET_ENV  = "INK_SECRET"
+)
+
+// Get secret to sign share tokens, environment variable takes precedence
+func shareSecret() []byte {
+	if secret := os.Getenv(SHARE_SECRET_ENV); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(globalConfig.Build.Secret)
+}
+
+func signShare(payload string) string {
+	mac := hmac.New(sha256.New, shareSecret())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Create token of markdown file expired after ttl
+func NewShareToken(file string, ttl time.Duration) string {
+	claims, _ := json.Marshal(ShareClaims{
+		File:    filepath.ToSlash(file),
+		Expires: time.Now().Add(ttl).Unix(),
+	})
+	payload := base64.RawURLEncoding.EncodeToString(claims)
+	return payload + "." + signShare(payload)
+}
+
+// Verify token and get claims
+func ParseShareToken(token string) (*ShareClaims, error) {
+	if len(shareSecret()) == 0 {
+		return nil, errors.New("share secret is not set")
+	}
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(signShare(parts[0])), []byte(parts[1])) {
+		return nil, errors.New("invalid share token")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	var claims ShareClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > claims.Expires {
+		return nil, errors.New("share token expired")
+	}
+	return &claims, nil
+}
+
+// Render shared article to html
+func renderShare(claims *ShareClaims) (data []byte, err error) {
+	// Config and templates are replaced by rebuilds of watcher
+	buildLock.Lock()
+	defer buildLock.Unlock()
+	markdownPath := filepath.Join(sourcePath, filepath.FromSlash(claims.File))
+	relPath, relErr := filepath.Rel(sourcePath, markdownPath)
+	if relErr != nil || strings.HasPrefix(relPath, "..") || strings.ToLower(filepath.Ext(markdownPath)) != ".md" {
+		return nil, errors.New("invalid shared file")
+	}
+	if !Exists(markdownPath) {
+		return nil, os.ErrNotExist
+	}
+	err = CatchBuildError(func() {
+		article := ParseArticle(markdownPath)
+		if article == nil {
+			BuildFatal("Invalid format: " + markdownPath)
+		}
+		var out bytes.Buffer
+		tpl := articleTpls.Get(ArticleTplPath(article, markdownPath))
+		if execErr := tpl.Execute(&out, RenderArticle{*article, nil, nil}); execErr != nil {
+			BuildFatal(NewTemplateError(execErr))
+		}
+		data = out.Bytes()
+	})
+	return data, err
+}
+
+// Serve draft under /preview/<token>/<link>, return false if path is not a share link
+func ServeShare(ctx *ink.Context, reqPath string) bool {
+	if !strings.HasPrefix(reqPath, SHARE_PREFIX) {
+		return false
+	}
+	token := strings.SplitN(strings.TrimPrefix(reqPath, SHARE_PREFIX), "/", 2)[0]
+	claims, err := ParseShareToken(token)
+	if err != nil {
+		Warn(err.Error())
+		http.NotFound(ctx.Res, ctx.Req)
+		return true
+	}
+	data, err := renderShare(claims)
+	if err != nil {
+		Warn(err.Error())
+		http.NotFound(ctx.Res, ctx.Req)
+		return true
+	}
+	header := ctx.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Cache-Control", "private, no-store")
+	header.Set("X-Robots-Tag", "noindex, nofollow")
+	ctx.Res.Write(data)
+	return true
+}
+
+// Print share link of markdown file
+func Share(c *cli.Context) {
+	if c.Args().Len() < 1 {
+		Fatal("Please specify the markdown file to share")
+	}
+	CheckArgs(c, 1)
+	file := c.Args().First()
+	ttl := c.Duration("ttl")
+	ParseGlobalConfigWrap(c.String("root"), false)
+	if globalConfig == nil {
+		Fatal("Parse config.yml failed, please specify a valid root path")
+	}
+	if len(shareSecret()) == 0 {
+		Fatal("Please set build.secret in config.yml or " + SHARE_SECRET_ENV + " environment variable")
+	}
+	InitArticleParser()
+	markdownPath, _ := filepath.Abs(file)
+	absSource, _ := filepath.Abs(sourcePath)
+	relPath, err := filepath.Rel(absSource, markdownPath)
+	if err != nil || strings.HasPrefix(relPath, "..") || !Exists(markdownPath) {
+		Fatal("File is not in source folder: " + file)
+	}
+	article := ParseArticle(markdownPath)
+	if article == nil {
+		Fatal("Invalid format: " + markdownPath)
+	}
+	token := NewShareToken(relPath, ttl)
+	link := globalConfig.Site.Url + SHARE_PREFIX + token + "/" + article.Link
+	Log(link)
+	Log("Expires at " + time.Now().Add(ttl).Format(DATE_FORMAT))
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Data of shortcode template
+type Shortcode struct {
+	Name string
+	// Named params, such as src of {{< figure src="a.png" >}}
+	Params map[string]string
+	// Positional params, such as a.png of {{< figure "a.png" >}}
+	Args []string
+	// Content between opening and closing tags
+	Inner template.HTML
+	// Count of shortcodes with the same name before this one in article
+	Ordinal int
+	Site    SiteConfig
+	Page    *ArticleConfig
+}
+
+// Shortcode tag in markdown
+type shortcodeTag struct {
+	start   int
+	end     int
+	name    string
+	closing bool
+	// Self-closing tag, such as {{< gist id />}}
+	selfClosing bool
+	// Inner content is markdown, opened by {{%
+	markdown bool
+	params   map[string]string
+	args     []string
+	// Escaped tag written as it is, such as {{</* figure */>}}
+	literal string
+}
+
+// Text or shortcode in markdown
+type shortcodeNode struct {
+	text     string
+	tag      *shortcodeTag
+	parent   *shortcodeNode
+	children []*shortcodeNode
+}
+
+// Markdown with shortcodes replaced by placeholders
+type shortcodeDoc struct {
+	path     string
+	source   string
+	config   *ArticleConfig
+	ordinals map[string]int
+	outputs  map[string]string
+}
+
+const SHORTCODES_DIR = "shortcodes"
+
+var shortcodeTpls *ThemeTpls
+
+// Get named param by string key or positional param by int index
+func (shortcode Shortcode) Get(key interface{}) string {
+	switch value := key.(type) {
+	case int:
+		if value >= 0 && value < len(shortcode.Args) {
+			return shortcode.Args[value]
+		}
+	case string:
+		return shortcode.Params[value]
+	}
+	return ""
+}
+
+// Render markdown of article, shortcodes are rendered by theme templates
+func ParseArticleMarkdown(markdownPath string, config *ArticleConfig, markdown string, toc bool) (template.HTML, []*TocItem) {
+	if shortcodeTpls == nil || !strings.Contains(markdown, "{{") {
+		return ParseMarkdownToc(markdown, toc)
+	}
+	doc := &shortcodeDoc{
+		path:     markdownPath,
+		source:   markdown,
+		config:   config,
+		ordinals: make(map[string]int),
+		outputs:  make(map[string]string),
+	}
+	text := doc.render(doc.parse(), true)
+	content, tocItems := ParseMarkdownToc(text, toc)
+	return template.HTML(doc.restore(string(content))), tocItems
+}
+
+// Get error at position of markdown
+func (doc *shortcodeDoc) error(pos int, message string) *BuildError {
+	line := 0
+	if data, err := os.ReadFile(doc.path); err == nil {
+		// Markdown starts after the front matter
+		if index := strings.Index(string(data), CONFIG_SPLIT); index >= 0 {
+			line = strings.Count(string(data[:index]), "\n") + 1
+		}
+	}
+	line += strings.Count(doc.source[:pos], "\n")
+	return &BuildError{File: doc.path, Line: line, Message: message}
+}
+
+// Stop building with error at position of markdown
+func (doc *shortcodeDoc) fatal(pos int, message string) {
+	BuildFatal(doc.error(pos, message))
+}
+
+// Find fenced code blocks and code spans, shortcodes in them are text
+func markdownCodeRanges(source string) [][2]int {
+	ranges := make([][2]int, 0)
+	// Code spans in text between fences
+	addSpans := func(start int, end int) {
+		text := source[start:end]
+		for pos := 0; pos < len(text); {
+			open := strings.IndexByte(text[pos:], '`')
+			if open < 0 {
+				break
+			}
+			open += pos
+			run := open
+			for run < len(text) && text[run] == '`' {
+				run++
+			}
+			delim := text[open:run]
+			closing := -1
+			for search := run; search < len(text); {
+				index := strings.Index(text[search:], delim)
+				if index < 0 {
+					break
+				}
+				index += search
+				// Closing run must have the same length
+				if index+len(delim) < len(text) && text[index+len(delim)] == '`' {
+					search = index + len(delim)
+					for search < len(text) && text[search] == '`' {
+						search++
+					}
+					continue
+				}
+				closing = index
+				break
+			}
+			if closing < 0 {
+				pos = run
+				continue
+			}
+			ranges = append(ranges, [2]int{start + open, start + closing + len(delim)})
+			pos = closing + len(delim)
+		}
+	}
+	textStart, fenceStart := 0, -1
+	fence := ""
+	for lineStart := 0; lineStart < len(source); {
+		lineEnd := strings.IndexByte(source[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(source)
+		} else {
+			lineEnd += lineStart + 1
+		}
+		line := strings.TrimLeft(source[lineStart:lineEnd], " ")
+		indent := lineEnd - lineStart - len(line)
+		if fenceStart < 0 {
+			if indent <= 3 && (strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")) {
+				fence = line[:len(line)-len(strings.TrimLeft(line, line[:1]))]
+				fenceStart = lineStart
+				addSpans(textStart, lineStart)
+			}
+		} else if indent <= 3 && strings.HasPrefix(line, fence) && strings.TrimSpace(strings.TrimLeft(line, fence[:1])) == "" {
+			ranges = append(ranges, [2]int{fenceStart, lineEnd})
+			fenceStart, textStart = -1, lineEnd
+		}
+		lineStart = lineEnd
+	}
+	if fenceStart >= 0 {
+		// Unclosed fence lasts to the end
+		ranges = append(ranges, [2]int{fenceStart, len(source)})
+	} else {
+		addSpans(textStart, len(source))
+	}
+	return ranges
+}
+
+// Check if shortcode name is a template in shortcodes folder
+func validShortcodeName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "/\\") && !strings.Contains(name, "..")
+}
+
+// Parse shortcode tags into a tree of nodes
+func (doc *shortcodeDoc) parse() *shortcodeNode {
+	root := &shortcodeNode{}
+	current := root
+	last := 0
+	for _, tag := range doc.scanTags() {
+		if tag.start > last {
+			current.children = append(current.children, &shortcodeNode{text: doc.source[last:tag.start]})
+		}
+		last = tag.end
+		switch {
+		case tag.literal != "":
+			current.children = append(current.children, &shortcodeNode{text: tag.literal})
+		case tag.closing:
+			open := current
+			for open != root && open.tag.name != tag.name {
+				open = open.parent
+			}
+			if open == root {
+				doc.fatal(tag.start, "unexpected closing shortcode: "+tag.name)
+			}
+			// Shortcodes opened after the matched one have no inner content
+			for current != open {
+				current = flattenShortcode(current)
+			}
+			current = open.parent
+		default:
+			node := &shortcodeNode{tag: tag, parent: current}
+			current.children = append(current.children, node)
+			if !tag.selfClosing {
+				current = node
+			}
+		}
+	}
+	if last < len(doc.source) {
+		current.children = append(current.children, &shortcodeNode{text: doc.source[last:]})
+	}
+	for current != root {
+		current = flattenShortcode(current)
+	}
+	return root
+}
+
+// Move children of unclosed shortcode to its parent, return the parent
+func flattenShortcode(node *shortcodeNode) *shortcodeNode {
+	parent := node.parent
+	for _, child := range node.children {
+		child.parent = parent
+	}
+	parent.children = append(parent.children, node.children...)
+	node.children = nil
+	node.tag.selfClosing = true
+	return parent
+}
+
+// Find all shortcode tags in markdown
+func (doc *shortcodeDoc) scanTags() []*shortcodeTag {
+	tags := make([]*shortcodeTag, 0)
+	source := doc.source
+	codeRanges := markdownCodeRanges(source)
+	for pos := 0; pos < len(source); {
+		index := strings.Index(source[pos:], "{{")
+		if index < 0 {
+			break
+		}
+		start := pos + index
+		inCode := false
+		for _, codeRange := range codeRanges {
+			if start >= codeRange[0] && start < codeRange[1] {
+				inCode = true
+				pos = codeRange[1]
+				break
+			}
+		}
+		if inCode {
+			continue
+		}
+		if start+2 >= len(source) || (source[start+2] != '<' && source[start+2] != '%') {
+			pos = start + 2
+			continue
+		}
+		markdown := source[start+2] == '%'
+		closeDelim := ">}}"
+		if markdown {
+			closeDelim = "%}}"
+		}
+		closeIndex := strings.Index(source[start+3:], closeDelim)
+		if closeIndex < 0 {
+			doc.fatal(start, "unclosed shortcode tag")
+		}
+		end := start + 3 + closeIndex + len(closeDelim)
+		tag := doc.parseTag(source[start+3:end-len(closeDelim)], start, end, markdown)
+		// Unknown shortcodes are kept as text, such as those of other blog systems
+		if tag.literal == "" && !validShortcodeName(tag.name) {
+			Warn(doc.error(start, "invalid shortcode name: "+tag.name).Error())
+			tag.literal = source[start:end]
+		} else if tag.literal == "" && shortcodeTpls.Get(tag.name) == nil {
+			Warn(doc.error(start, "shortcode template not found: "+filepath.Join(SHORTCODES_DIR, tag.name+".html")).Error())
+			tag.literal = source[start:end]
+		}
+		tags = append(tags, tag)
+		pos = end
+	}
+	return tags
+}
+
+// Parse content of tag, such as figure src="a.png"
+func (doc *shortcodeDoc) parseTag(content string, start int, end int, markdown bool) *shortcodeTag {
+	tag := &shortcodeTag{start: start, end: end, markdown: markdown, params: make(map[string]string)}
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "/*") && strings.HasSuffix(trimmed, "*/") {
+		inner := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(trimmed, "/*"), "*/"))
+		tag.literal = "{{< " + inner + " >}}"
+		if markdown {
+			tag.literal = "{{% " + inner + " %}}"
+		}
+		return tag
+	}
+	if strings.HasPrefix(trimmed, "/") {
+		tag.closing = true
+		tag.name = strings.TrimSpace(strings.TrimPrefix(trimmed, "/"))
+		return tag
+	}
+	if strings.HasSuffix(trimmed, "/") {
+		tag.selfClosing = true
+		trimmed = strings.TrimSuffix(trimmed, "/")
+	}
+	tokens, err := splitShortcodeParams(trimmed)
+	if err != nil {
+		doc.fatal(start, err.Error())
+	}
+	if len(tokens) == 0 {
+		doc.fatal(start, "missing shortcode name")
+	}
+	tag.name = tokens[0]
+	for _, token := range tokens[1:] {
+		if index := strings.Index(token, "="); index > 0 && !strings.HasPrefix(token, "\"") && !strings.HasPrefix(token, "`") {
+			tag.params[token[:index]] = unquoteShortcodeParam(token[index+1:])
+		} else {
+			tag.args = append(tag.args, unquoteShortcodeParam(token))
+		}
+	}
+	return tag
+}
+
+// Split params by spaces outside of quotes
+func splitShortcodeParams(content string) ([]string, error) {
+	tokens := make([]string, 0)
+	var token strings.Builder
+	var quote rune
+	escaped := false
+	for _, char := range content {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && char == '\\':
+			escaped = true
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '`':
+			quote = char
+		case unicode.IsSpace(char):
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+			continue
+		}
+		token.WriteRune(char)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote in shortcode: %s", content)
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
+
+func unquoteShortcodeParam(value string) string {
+	if len(value) >= 2 && value[0] == '`' && value[len(value)-1] == '`' {
+		return value[1 : len(value)-1]
+	}
+	if len(value) >= 2 && value[0] == '"' {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	}
+	return value
+}
+
+// Render nodes, top level shortcodes are replaced by placeholders
+func (doc *shortcodeDoc) render(node *shortcodeNode, top bool) string {
+	var out strings.Builder
+	for _, child := range node.children {
+		if child.tag == nil {
+			out.WriteString(child.text)
+			continue
+		}
+		html := doc.renderShortcode(child)
+		if top {
+			placeholder := fmt.Sprintf("INKSHORTCODE%dEND", len(doc.outputs))
+			doc.outputs[placeholder] = html
+			html = placeholder
+		}
+		out.WriteString(html)
+	}
+	return out.String()
+}
+
+// Render shortcode with its template
+func (doc *shortcodeDoc) renderShortcode(node *shortcodeNode) string {
+	tag := node.tag
+	tpl := shortcodeTpls.Get(tag.name)
+	inner := doc.render(node, false)
+	if tag.markdown {
+		inner = string(ParseMarkdown(inner, false))
+	}
+	data := Shortcode{
+		Name:    tag.name,
+		Params:  tag.params,
+		Args:    tag.args,
+		Inner:   template.HTML(inner),
+		Ordinal: doc.ordinals[tag.name],
+		Site:    globalConfig.Site,
+		Page:    doc.config,
+	}
+	doc.ordinals[tag.name]++
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		doc.fatal(tag.start, "shortcode "+tag.name+": "+NewTemplateError(err).Error())
+	}
+	return out.String()
+}
+
+// Replace placeholders in html by rendered shortcodes
+func (doc *shortcodeDoc) restore(html string) string {
+	for placeholder, output := range doc.outputs {
+		// Shortcode in its own paragraph is a block
+		html = strings.Replace(html, "<p>"+placeholder+"</p>", output, -1)
+		html = strings.Replace(html, placeholder, output, -1)
+	}
+	return html
+}
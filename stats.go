@@ -0,0 +1,116 @@
+package main
+
+import (
+	"html/template"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+type ReadingConfig struct {
+	// Words read per minute, default 300
+	Wpm int
+	// Characters of summary generated from content, default 140
+	Summary int
+}
+
+// Elements whose text is not part of the article body
+var skipTextTags = map[string]bool{
+	"script": true,
+	"style":  true,
+	"nav":    true,
+}
+
+// Get plain text of html, whitespace is collapsed
+func HTMLText(content template.HTML) string {
+	var text strings.Builder
+	skipDepth := 0
+	tokenizer := html.NewTokenizer(strings.NewReader(string(content)))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		switch tokenType {
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if skipTextTags[string(name)] {
+				skipDepth++
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if skipDepth > 0 && skipTextTags[string(name)] {
+				skipDepth--
+			}
+		case html.TextToken:
+			if skipDepth == 0 {
+				text.Write(tokenizer.Text())
+				text.WriteString(" ")
+			}
+		}
+	}
+	return strings.Join(strings.Fields(text.String()), " ")
+}
+
+// Check if rune is counted as a word by itself
+func isCJK(char rune) bool {
+	return unicode.In(char, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// Count words of text, each CJK character is a word
+func WordCount(text string) int {
+	count := 0
+	inWord := false
+	for _, char := range text {
+		switch {
+		case isCJK(char):
+			count++
+			inWord = false
+		case unicode.IsLetter(char) || unicode.IsNumber(char):
+			if !inWord {
+				count++
+			}
+			inWord = true
+		case char == '\'' || char == '’' || char == '-' || char == '_':
+			// Part of words such as don't and well-known
+		default:
+			inWord = false
+		}
+	}
+	return count
+}
+
+// Get reading time in minutes, at least one minute for any content
+func ReadingTime(words int) int {
+	wpm := 300
+	if globalConfig != nil && globalConfig.Site.Reading.Wpm > 0 {
+		wpm = globalConfig.Site.Reading.Wpm
+	}
+	return int(math.Ceil(float64(words) / float64(wpm)))
+}
+
+// Cut text to length in characters, end with ellipsis if cut
+func Summarize(text string) string {
+	length := 140
+	if globalConfig != nil && globalConfig.Site.Reading.Summary > 0 {
+		length = globalConfig.Site.Reading.Summary
+	}
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	runes := []rune(text)
+	cut := length
+	// Do not break latin words
+	if !isCJK(runes[cut]) {
+		for i := cut; i > length/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+	}
+	return strings.TrimSpace(string(runes[:cut])) + "…"
+}
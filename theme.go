@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/facebookgo/symwalk"
+	"github.com/urfave/cli/v2"
+)
+
+// Optional templates of a theme folder compiled on first use
+type ThemeTpls struct {
+	sync.Mutex
+	dir      string
+	partials []PartialTpl
+	funcCxt  FuncContext
+	tpls     map[string]*template.Template
+}
+
+// Site-local templates and static files overriding the theme
+const LAYOUTS_DIR = "layouts"
+
+// Template directories by priority: layouts, theme, parent themes
+var templateDirs []string
+
+// Parse theme config and its parents declared by extends, child values win
+func ParseThemeChain(themeDir string) *ThemeConfig {
+	merged := &ThemeConfig{Lang: make(map[string]map[string]string)}
+	copySet := make(map[string]bool)
+	visited := make(map[string]bool)
+	for dir := themeDir; dir != ""; {
+		absDir, _ := filepath.Abs(dir)
+		if visited[absDir] {
+			BuildFatal("Theme extends itself: " + dir)
+		}
+		visited[absDir] = true
+		if !Exists(dir) {
+			BuildFatal("Theme not found: " + dir)
+		}
+		config := ParseThemeConfig(filepath.Join(dir, "config.yml"))
+		if config == nil {
+			config = &ThemeConfig{}
+		}
+		merged.Chain = append(merged.Chain, dir)
+		for _, copyItem := range config.Copy {
+			if !copySet[copyItem] {
+				copySet[copyItem] = true
+				merged.Copy = append(merged.Copy, copyItem)
+			}
+		}
+		for item, langItem := range config.Lang {
+			if _, ok := merged.Lang[item]; !ok {
+				merged.Lang[item] = make(map[string]string)
+			}
+			for lang, text := range langItem {
+				if _, ok := merged.Lang[item][lang]; !ok {
+					merged.Lang[item][lang] = text
+				}
+			}
+		}
+		if config.Extends == "" {
+			break
+		}
+		// Parent theme is relative to the theme directory
+		dir = filepath.Join(dir, config.Extends)
+	}
+	return merged
+}
+
+// Get template directories of site and theme chain
+func TemplateDirs(root string, theme *ThemeConfig) []string {
+	dirs := make([]string, 0)
+	layoutsPath := filepath.Join(root, LAYOUTS_DIR)
+	if info, err := os.Stat(layoutsPath); err == nil && info.IsDir() {
+		dirs = append(dirs, layoutsPath)
+	}
+	return append(dirs, theme.Chain...)
+}
+
+// Find file in template directories, return empty string if not found
+func LookupTemplate(name string) string {
+	for _, dir := range templateDirs {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// Find required template, stop building if not found
+func MustLookupTemplate(name string) string {
+	path := LookupTemplate(name)
+	if path == "" {
+		BuildFatal("Template not found: " + name + " in " + strings.Join(templateDirs, ", "))
+	}
+	return path
+}
+
+// Templates which can not be selected by article type
+var reservedTemplates = map[string]bool{
+	"article": true,
+	"list":    true,
+	"archive": true,
+	"tag":     true,
+	"404":     true,
+}
+
+// Find list template, page.html is the list template of themes without list.html
+func LookupListTemplate() string {
+	if path := LookupTemplate("list.html"); path != "" {
+		return path
+	}
+	return MustLookupTemplate("page.html")
+}
+
+// Find template of article by layout, type and then article.html
+func ArticleTplPath(article *Article, markdownPath string) string {
+	if article.Layout != "" {
+		name := strings.TrimSuffix(article.Layout, ".html") + ".html"
+		path := LookupTemplate(name)
+		if path == "" {
+			BuildFatal(&BuildError{File: markdownPath, Line: fieldLine(markdownPath, "layout"), Message: "layout not found: " + name})
+		}
+		return path
+	}
+	typeName := strings.ToLower(article.Type)
+	if typeName != "" && !reservedTemplates[typeName] {
+		path := LookupTemplate(typeName + ".html")
+		// page.html of old themes is the list template
+		if path != "" && path != listTplPath {
+			return path
+		}
+	}
+	return MustLookupTemplate("article.html")
+}
+
+func NewThemeTpls(dir string, partials []PartialTpl, funcCxt FuncContext) *ThemeTpls {
+	return &ThemeTpls{
+		dir:      dir,
+		partials: partials,
+		funcCxt:  funcCxt,
+		tpls:     make(map[string]*template.Template),
+	}
+}
+
+// Get compiled template by name without .html, nil if not found
+func (themeTpls *ThemeTpls) Get(name string) *template.Template {
+	themeTpls.Lock()
+	defer themeTpls.Unlock()
+	if tpl, ok := themeTpls.tpls[name]; ok {
+		return tpl
+	}
+	var tpl *template.Template
+	if tplPath := LookupTemplate(filepath.Join(themeTpls.dir, name+".html")); tplPath != "" {
+		compiled := CompileTpl(tplPath, themeTpls.partials, path.Join(themeTpls.dir, name), themeTpls.funcCxt)
+		tpl = &compiled
+	}
+	themeTpls.tpls[name] = tpl
+	return tpl
+}
+
+// Find partials in template directories, partial of higher priority wins
+func LookupPartials() []PartialTpl {
+	partials := make([]PartialTpl, 0)
+	found := make(map[string]bool)
+	for _, dir := range templateDirs {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.html"))
+		for _, path := range files {
+			baseName := strings.ToLower(filepath.Base(path))
+			if !strings.HasPrefix(baseName, "_") {
+				continue
+			}
+			tplName := strings.TrimSuffix(strings.TrimPrefix(baseName, "_"), ".html")
+			if found[tplName] {
+				continue
+			}
+			found[tplName] = true
+			html, err := os.ReadFile(path)
+			if err != nil {
+				BuildFatal(err.Error())
+			}
+			partials = append(partials, PartialTpl{tplName, path, string(html)})
+		}
+	}
+	// Themes render comments of any provider by the built-in partial
+	if !found[COMMENTS_PARTIAL] {
+		partials = append(partials, PartialTpl{COMMENTS_PARTIAL, "", DEFAULT_COMMENTS_PARTIAL})
+	}
+	return partials
+}
+
+// Plan static files of theme, file of higher priority wins
+func (plan *BuildPlan) AddThemeCopy(copyList []string) []CopyItem {
+	items := make([]CopyItem, 0)
+	planned := make(map[string]bool)
+	add := func(relPath string, source string) {
+		if planned[relPath] {
+			return
+		}
+		planned[relPath] = true
+		if plan.Add(relPath, source) {
+			items = append(items, CopyItem{source, filepath.Join(publicPath, relPath)})
+		}
+	}
+	for _, copyItem := range copyList {
+		for _, dir := range templateDirs {
+			srcPath := filepath.Join(dir, copyItem)
+			file, err := os.Stat(srcPath)
+			if err != nil {
+				continue
+			}
+			if !file.IsDir() {
+				add(file.Name(), srcPath)
+				continue
+			}
+			baseDir := filepath.Dir(srcPath)
+			symwalk.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+				if info == nil || info.IsDir() {
+					return nil
+				}
+				relPath, _ := filepath.Rel(baseDir, path)
+				add(relPath, path)
+				return nil
+			})
+		}
+	}
+	return items
+}
+
+// Print which file is used for each template
+func ListLayouts(c *cli.Context) {
+	ParseGlobalConfigByCli(c, false)
+	templateDirs = TemplateDirs(rootPath, themeConfig)
+	fmt.Println("Lookup order:")
+	for _, dir := range templateDirs {
+		fmt.Println("  " + dir)
+	}
+	names := make([]string, 0)
+	nameSet := make(map[string]bool)
+	for _, dir := range templateDirs {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.html"))
+		for _, path := range files {
+			name := filepath.Base(path)
+			if !nameSet[name] {
+				nameSet[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	fmt.Println("Templates:")
+	for _, name := range names {
+		winner := LookupTemplate(name)
+		fmt.Printf("  %-20s %s\n", name, winner)
+		for _, dir := range templateDirs {
+			path := filepath.Join(dir, name)
+			if path != winner && Exists(path) {
+				fmt.Printf("  %-20s   shadows %s\n", "", path)
+			}
+		}
+	}
+}
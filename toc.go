@@ -0,0 +1,119 @@
+package main
+
+import (
+	"html/template"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+type TocConfig struct {
+	// Heading levels included, default 1 to 6
+	Min int
+	Max int
+	// Prepend table of contents to content of articles with toc, default true
+	Inline *bool
+}
+
+// Heading in table of contents
+type TocItem struct {
+	Level    int
+	Text     string
+	Anchor   string
+	Children []*TocItem
+}
+
+// Get heading level range of table of contents
+func tocLevels() (int, int) {
+	minLevel, maxLevel := 1, 6
+	if globalConfig != nil {
+		if level := globalConfig.Site.Toc.Min; level >= 1 && level <= 6 {
+			minLevel = level
+		}
+		if level := globalConfig.Site.Toc.Max; level >= minLevel && level <= 6 {
+			maxLevel = level
+		}
+	}
+	return minLevel, maxLevel
+}
+
+// Check if table of contents is prepended to content
+func TocInline() bool {
+	return globalConfig == nil || globalConfig.Site.Toc.Inline == nil || *globalConfig.Site.Toc.Inline
+}
+
+// Set toc_N ids of headings without id, N counts headings of document
+func numberHeadingIDs(doc ast.Node) {
+	count := 0
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering || heading.IsTitleblock {
+			return ast.GoToNext
+		}
+		if heading.HeadingID == "" {
+			heading.HeadingID = "toc_" + strconv.Itoa(count)
+		}
+		count++
+		return ast.SkipChildren
+	})
+}
+
+// Build tree of headings in markdown document
+func TableOfContents(doc ast.Node) []*TocItem {
+	minLevel, maxLevel := tocLevels()
+	items := make([]*TocItem, 0)
+	// Last item of each level, parent of following deeper headings
+	parents := make([]*TocItem, 0)
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering || heading.IsTitleblock {
+			return ast.GoToNext
+		}
+		if heading.Level < minLevel || heading.Level > maxLevel {
+			return ast.SkipChildren
+		}
+		item := &TocItem{
+			Level:  heading.Level,
+			Text:   strings.TrimSpace(plainText(heading)),
+			Anchor: heading.HeadingID,
+		}
+		for len(parents) > 0 && parents[len(parents)-1].Level >= item.Level {
+			parents = parents[:len(parents)-1]
+		}
+		if len(parents) == 0 {
+			items = append(items, item)
+		} else {
+			parent := parents[len(parents)-1]
+			parent.Children = append(parent.Children, item)
+		}
+		parents = append(parents, item)
+		return ast.SkipChildren
+	})
+	return items
+}
+
+func writeTocItems(out *strings.Builder, items []*TocItem) {
+	out.WriteString("<ul>\n")
+	for _, item := range items {
+		out.WriteString(`<li><a href="#` + template.HTMLEscapeString(item.Anchor) + `">` + template.HTMLEscapeString(item.Text) + "</a>")
+		if len(item.Children) > 0 {
+			out.WriteString("\n")
+			writeTocItems(out, item.Children)
+		}
+		out.WriteString("</li>\n")
+	}
+	out.WriteString("</ul>\n")
+}
+
+// Render table of contents to nested list
+func RenderToc(items []*TocItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	var out strings.Builder
+	out.WriteString(`<nav class="toc">` + "\n")
+	writeTocItems(&out, items)
+	out.WriteString("</nav>\n")
+	return out.String()
+}
@@ -5,7 +5,9 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"strings"
 	"time"
+	"unicode"
 )
 
 const (
@@ -23,26 +25,29 @@ const (
 
 var exitCode int
 
+// Output of logs, stderr when stdout is used for data
+var logOutput io.Writer = os.Stdout
+
 // Print log
 func Log(info interface{}) {
-	fmt.Printf("%s\n", info)
+	fmt.Fprintf(logOutput, "%s\n", info)
 }
 
 // Print warning log
 func Warn(info interface{}) {
 	if runtime.GOOS == "windows" {
-		fmt.Printf("WARNING: %s\n", info)
+		fmt.Fprintf(logOutput, "WARNING: %s\n", info)
 	} else {
-		fmt.Printf("%s%s\n%s", CLR_Y, info, "\x1b[0m")
+		fmt.Fprintf(logOutput, "%s%s\n%s", CLR_Y, info, "\x1b[0m")
 	}
 }
 
 // Print error log
 func Error(info interface{}) {
 	if runtime.GOOS == "windows" {
-		fmt.Printf("ERR: %s\n", info)
+		fmt.Fprintf(logOutput, "ERR: %s\n", info)
 	} else {
-		fmt.Printf("%s%s\n%s", CLR_R, info, "\x1b[0m")
+		fmt.Fprintf(logOutput, "%s%s\n%s", CLR_R, info, "\x1b[0m")
 	}
 	exitCode = 1
 }
@@ -55,14 +60,48 @@ func Fatal(info interface{}) {
 
 // Parse date by std date string
 func ParseDate(dateStr string) time.Time {
+	date, err := ParseDateString(dateStr)
+	if err != nil {
+		BuildFatal(err.Error())
+	}
+	return date
+}
+
+// Parse date by std date string, with or without timezone
+func ParseDateString(dateStr string) (time.Time, error) {
 	date, err := time.Parse(fmt.Sprint(DATE_FORMAT_WITH_TIMEZONE), dateStr)
 	if err != nil {
 		date, err = time.ParseInLocation(fmt.Sprint(DATE_FORMAT), dateStr, time.Now().Location())
-		if err != nil {
-			Fatal(err.Error())
+	}
+	return date, err
+}
+
+// Check string if only contains ASCII characters
+func IsASCII(str string) bool {
+	for _, r := range str {
+		if r > unicode.MaxASCII {
+			return false
 		}
 	}
-	return date
+	return true
+}
+
+// Convert string to lowercase words joined by hyphen
+func Slugify(str string) string {
+	var slug strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(str) {
+		if r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if hyphen && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return slug.String()
 }
 
 // Check file if exist
@@ -89,56 +128,40 @@ func IsDir(path string) bool {
 // Copy folder and file
 // Refer to https://www.socketloop.com/tutorials/golang-copy-directory-including-sub-directories-files
 func CopyFile(source string, dest string) {
+	defer wg.Done()
+	defer RecoverBuildError()
+	sourceinfo, err := os.Stat(source)
+	if err != nil {
+		BuildFatal(err.Error())
+	}
+	// Minify text file instead of copying it as it is
+	if ShouldMinify(dest) {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			BuildFatal(err.Error())
+		}
+		err = os.WriteFile(dest, MinifyBytes(dest, data), sourceinfo.Mode())
+		if err != nil {
+			BuildFatal(err.Error())
+		}
+		return
+	}
 	sourcefile, err := os.Open(source)
 	if err != nil {
-		Fatal(err.Error())
+		BuildFatal(err.Error())
 	}
+	defer sourcefile.Close()
 	destfile, err := os.Create(dest)
 	if err != nil {
-		Fatal(err.Error())
+		BuildFatal(err.Error())
 	}
 	defer destfile.Close()
-	defer wg.Done()
 	_, err = io.Copy(destfile, sourcefile)
 	if err != nil {
-		Fatal(err.Error())
-	}
-	sourceinfo, err := os.Stat(source)
-	if err != nil {
-		Fatal(err.Error())
+		BuildFatal(err.Error())
 	}
 	err = os.Chmod(dest, sourceinfo.Mode())
 	if err != nil {
-		Fatal(err.Error())
-	}
-	sourcefile.Close()
-}
-
-func CopyDir(source string, dest string) {
-	sourceinfo, err := os.Stat(source)
-	if err != nil {
-		Fatal(err.Error())
-	}
-	err = os.MkdirAll(dest, sourceinfo.Mode())
-	if err != nil {
-		Fatal(err.Error())
-	}
-	directory, _ := os.Open(source)
-	defer directory.Close()
-	defer wg.Done()
-	objects, err := directory.Readdir(-1)
-	if err != nil {
-		Fatal(err.Error())
-	}
-	for _, obj := range objects {
-		sourcefilepointer := source + "/" + obj.Name()
-		destinationfilepointer := dest + "/" + obj.Name()
-		if obj.IsDir() {
-			wg.Add(1)
-			CopyDir(sourcefilepointer, destinationfilepointer)
-		} else {
-			wg.Add(1)
-			go CopyFile(sourcefilepointer, destinationfilepointer)
-		}
+		BuildFatal(err.Error())
 	}
 }